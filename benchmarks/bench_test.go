@@ -27,8 +27,6 @@ func init() {
 	dbs, _ := data.NewDBs(cfg)
 	db = dbs[0]
 
-	ensureOutboxTableExists()
-
 	repo = outbox.NewRepository(dbs[0], cfg)
 	syncProducer = benchkafka.NewSyncProducer(cfg.KafkaHost)
 	pub = kafka.NewPublisherWithProducer(syncProducer)
@@ -41,13 +39,6 @@ func purgeOutboxTable() {
 	}
 }
 
-func ensureOutboxTableExists() {
-	_, err := db.Connection().Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE kafka_outbox;", db.Config().OutboxTable))
-	if err != nil {
-		panic(fmt.Sprintf("an error occurred creating the outbox table for integration tests: %s", err))
-	}
-}
-
 func insertOutboxMessages(msgs []*outbox.Message) {
 	tx, err := db.Connection().Begin()
 	if err != nil {
@@ -40,8 +40,8 @@ func (sp *SyncProducer) ResetMessagesPublishedCount() {
 }
 
 func (sp *SyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
-	sp.RLock()
-	defer sp.RUnlock()
+	sp.Lock()
+	defer sp.Unlock()
 
 	pt, off, err := sp.realSyncProducer.SendMessage(msg)
 	sp.msgsPublished++
@@ -50,9 +50,43 @@ func (sp *SyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int3
 }
 
 func (sp *SyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
-	return nil
+	sp.Lock()
+	defer sp.Unlock()
+
+	err := sp.realSyncProducer.SendMessages(msgs)
+	sp.msgsPublished += len(msgs)
+
+	return err
 }
 
 func (sp *SyncProducer) Close() error {
 	return nil
 }
+
+func (sp *SyncProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return sp.realSyncProducer.TxnStatus()
+}
+
+func (sp *SyncProducer) IsTransactional() bool {
+	return sp.realSyncProducer.IsTransactional()
+}
+
+func (sp *SyncProducer) BeginTxn() error {
+	return sp.realSyncProducer.BeginTxn()
+}
+
+func (sp *SyncProducer) CommitTxn() error {
+	return sp.realSyncProducer.CommitTxn()
+}
+
+func (sp *SyncProducer) AbortTxn() error {
+	return sp.realSyncProducer.AbortTxn()
+}
+
+func (sp *SyncProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupId string) error {
+	return sp.realSyncProducer.AddOffsetsToTxn(offsets, groupId)
+}
+
+func (sp *SyncProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupId string, metadata *string) error {
+	return sp.realSyncProducer.AddMessageToTxn(msg, groupId, metadata)
+}
@@ -5,8 +5,10 @@ package benchmarks
 
 import (
 	"context"
+	"io"
 	"testing"
 
+	"inviqa/kafka-outbox-relay/kafka"
 	"inviqa/kafka-outbox-relay/outbox"
 	"inviqa/kafka-outbox-relay/outbox/processor"
 )
@@ -18,11 +20,34 @@ const (
 	batchSize = 50
 )
 
+// messagePublisher hides kafka.Publisher's PublishBatch from the batch processor,
+// so that messages are published one at a time and can be compared against
+// publishing the whole batch at once
+type messagePublisher struct {
+	pub kafka.Publisher
+}
+
+func (m messagePublisher) PublishMessage(msg *outbox.Message) error {
+	return m.pub.PublishMessage(msg)
+}
+
+func (m messagePublisher) Close() error {
+	return m.pub.Close()
+}
+
 func BenchmarkOutboxPollAndPublishToKafka(b *testing.B) {
+	benchmarkPollAndPublish(b, pub)
+}
+
+func BenchmarkOutboxPollAndPublishToKafkaOneMessageAtATime(b *testing.B) {
+	benchmarkPollAndPublish(b, messagePublisher{pub: pub})
+}
+
+func benchmarkPollAndPublish(b *testing.B, p publisher) {
 	cfg.BatchSize = batchSize
 	repo = outbox.NewRepository(db, cfg)
 	batchCh := make(chan *outbox.Batch)
-	proc := processor.NewBatchProcessor(repo, pub)
+	proc := processor.NewBatchProcessor(repo, p, nil)
 	go proc.ListenAndProcess(context.Background(), batchCh)
 
 	purgeOutboxTable()
@@ -34,7 +59,7 @@ func BenchmarkOutboxPollAndPublishToKafka(b *testing.B) {
 	// measure the performance of it, instead we implement the same simple for loop here
 	// but wait for the Kafka producer to publish all messages in the batch
 	for i := 0; i < b.N; i++ {
-		batch, err := repo.GetBatch()
+		batch, err := repo.GetBatch(context.Background())
 		if err != nil {
 			b.Fatalf("an error occurred during repo.GetBatch(): %s", err)
 		}
@@ -50,6 +75,11 @@ func BenchmarkOutboxPollAndPublishToKafka(b *testing.B) {
 	}
 }
 
+type publisher interface {
+	io.Closer
+	PublishMessage(m *outbox.Message) error
+}
+
 func populateOutbox() {
 	var msgs []*outbox.Message
 	for i := 0; i < numMessagesToPopulateOutboxWith; i++ {
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultKafkaCluster is the name of the Kafka cluster configured by KAFKA_HOST,
+// which messages are published to unless their topic is routed elsewhere.
+const DefaultKafkaCluster = "default"
+
+// indexedKafkaVar matches the env vars used to configure each additional Kafka
+// cluster, e.g. KAFKA_1_HOST or KAFKA_2_SASL_MECHANISM.
+var indexedKafkaVar = regexp.MustCompile(`^KAFKA_(\d+)_([A-Z_]+)$`)
+
+// KafkaCluster holds the connection settings for a Kafka cluster.
+type KafkaCluster struct {
+	Name              string
+	Host              []string
+	TLSEnable         bool
+	TLSSkipVerifyPeer bool
+	TLSFiles          TLSFiles
+	SASL              SASL
+}
+
+// kafkaClustersConfig creates a config for each additional Kafka cluster from
+// the indexed KAFKA_<n>_* env vars. The additional clusters use the shared TLS
+// settings unless they are overridden, but never the shared SASL credentials,
+// so that they cannot be sent to the wrong cluster by mistake.
+func kafkaClustersConfig(a *args, environ []string) ([]KafkaCluster, error) {
+	indexed, err := indexedEnv(environ, indexedKafkaVar)
+	if err != nil {
+		return nil, err
+	}
+
+	var clusters []KafkaCluster
+
+	indices := make([]int, 0, len(indexed))
+	for i := range indexed {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+
+	for _, i := range indices {
+		c := KafkaCluster{
+			TLSEnable:         a.TLSEnable,
+			TLSSkipVerifyPeer: a.TLSSkipVerifyPeer,
+			TLSFiles:          a.kafkaTLSFiles(),
+		}
+		for key, val := range indexed[i] {
+			if err := c.set(key, val); err != nil {
+				return nil, fmt.Errorf("the KAFKA_%d_%s provided (%s) is not valid: %w", i, key, val, err)
+			}
+		}
+		clusters = append(clusters, c)
+	}
+
+	return clusters, nil
+}
+
+// set overrides the cluster setting with the given KAFKA_<n>_* env var suffix.
+func (c *KafkaCluster) set(key, val string) error {
+	var err error
+	switch key {
+	case "NAME":
+		c.Name = val
+	case "HOST":
+		c.Host = strings.Split(val, ",")
+	case "TLS_ENABLE":
+		c.TLSEnable, err = strconv.ParseBool(val)
+	case "TLS_SKIP_VERIFY_PEER":
+		c.TLSSkipVerifyPeer, err = strconv.ParseBool(val)
+	case "TLS_CA_FILE":
+		c.TLSFiles.CAFile = val
+	case "TLS_CERT_FILE":
+		c.TLSFiles.CertFile = val
+	case "TLS_KEY_FILE":
+		c.TLSFiles.KeyFile = val
+	case "SASL_MECHANISM":
+		c.SASL.Mechanism = val
+	case "SASL_USER":
+		c.SASL.User = val
+	case "SASL_USER_FILE":
+		c.SASL.UserFile = val
+	case "SASL_PASS":
+		c.SASL.Password = val
+	case "SASL_PASS_FILE":
+		c.SASL.PasswordFile = val
+	case "SASL_OAUTH_TOKEN":
+		c.SASL.OAuthToken = val
+	case "SASL_OAUTH_TOKEN_FILE":
+		c.SASL.OAuthTokenFile = val
+	default:
+		err = fmt.Errorf("unknown Kafka cluster setting")
+	}
+
+	return err
+}
+
+// kafkaRoutes parses the KAFKA_ROUTES, in the form <topic pattern>=<cluster>.
+// The clusters are validated along with the rest of the clusters.
+func kafkaRoutes(routes []string) (TopicPatterns, []error) {
+	return parseTopicPatterns("KAFKA_ROUTES", "cluster", "compliance.*=compliance", routes, nil)
+}
+
+// validateKafkaClusters validates the additional Kafka clusters, along with the
+// routes to them. The default cluster is validated with the rest of the shared
+// env vars.
+func validateKafkaClusters(clusters []KafkaCluster, routes TopicPatterns) []error {
+	var errs []error
+	names := map[string]bool{DefaultKafkaCluster: true}
+	for _, c := range clusters {
+		errs = append(errs, c.validate()...)
+
+		if names[c.Name] {
+			errs = append(errs, fmt.Errorf("the Kafka cluster name %s is configured more than once, or is reserved", c.Name))
+		}
+		names[c.Name] = true
+	}
+
+	for _, r := range routes {
+		if !names[r.Value] {
+			errs = append(errs, fmt.Errorf("the topic pattern %s is routed to an unknown Kafka cluster %s", r.Pattern, r.Value))
+		}
+	}
+
+	return errs
+}
+
+func (c KafkaCluster) validate() []error {
+	var errs []error
+	if c.Name == "" {
+		errs = append(errs, fmt.Errorf("a name must be provided for every Kafka cluster"))
+	}
+	if len(c.Host) == 0 || c.Host[0] == "" {
+		errs = append(errs, fmt.Errorf("a host must be provided for the Kafka cluster %s", c.Name))
+	}
+	for _, err := range c.SASL.validate() {
+		errs = append(errs, fmt.Errorf("the SASL settings for the Kafka cluster %s are not valid: %w", c.Name, err))
+	}
+	errs = append(errs, c.TLSFiles.validate("the Kafka cluster "+c.Name, c.TLSEnable)...)
+
+	return errs
+}
+
+// AllKafkaClusters returns every Kafka cluster that messages can be published
+// to, starting with the default cluster configured by the shared KAFKA_* env
+// vars.
+func (c *Config) AllKafkaClusters() []KafkaCluster {
+	clusters := []KafkaCluster{{
+		Name:              DefaultKafkaCluster,
+		Host:              c.KafkaHost,
+		TLSEnable:         c.TLSEnable,
+		TLSSkipVerifyPeer: c.TLSSkipVerifyPeer,
+		TLSFiles:          c.KafkaTLSFiles,
+		SASL:              c.KafkaSASL,
+	}}
+
+	return append(clusters, c.KafkaClusters...)
+}
+
+// KafkaClusterFor returns the name of the Kafka cluster that messages for the
+// topic are published to, which is the cluster of the first route whose
+// pattern matches the topic, or the default cluster.
+func (c *Config) KafkaClusterFor(topic string) string {
+	if cluster, ok := c.KafkaRoutes.For(topic); ok {
+		return cluster
+	}
+
+	return DefaultKafkaCluster
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestNewConfig_WithKafkaClusters(t *testing.T) {
+	os.Args = nil
+	defer os.Clearenv()
+	for k, v := range getEnvVars(map[string]string{
+		"TLS_ENABLE":             "true",
+		"KAFKA_ROUTES":           "compliance.*=compliance,audit=compliance",
+		"KAFKA_1_NAME":           "compliance",
+		"KAFKA_1_HOST":           "compliance-kafka:9092,compliance-kafka2:9092",
+		"KAFKA_1_SASL_MECHANISM": SASLPlain,
+		"KAFKA_1_SASL_USER":      "relay",
+		"KAFKA_1_SASL_PASS":      "secret",
+	}) {
+		os.Setenv(k, v)
+	}
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := []KafkaCluster{{
+		Name:      "compliance",
+		Host:      []string{"compliance-kafka:9092", "compliance-kafka2:9092"},
+		TLSEnable: true,
+		SASL:      SASL{Mechanism: SASLPlain, User: "relay", Password: "secret"},
+	}}
+	if diff := deep.Equal(exp, cfg.KafkaClusters); diff != nil {
+		t.Error(diff)
+	}
+
+	for topic, cluster := range map[string]string{
+		"compliance.checks": "compliance",
+		"audit":             "compliance",
+		"audit.log":         DefaultKafkaCluster,
+		"orders":            DefaultKafkaCluster,
+	} {
+		if got := cfg.KafkaClusterFor(topic); got != cluster {
+			t.Errorf("expected the topic %s to be routed to the %s cluster, but got %s", topic, cluster, got)
+		}
+	}
+
+	expAddrs := map[string][]string{
+		DefaultKafkaCluster: {"kafka"},
+		"compliance":        {"compliance-kafka:9092", "compliance-kafka2:9092"},
+	}
+	if diff := deep.Equal(expAddrs, cfg.KafkaClusterAddresses()); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestNewConfig_WithInvalidKafkaClusters(t *testing.T) {
+	os.Args = nil
+	defer os.Clearenv()
+	for k, v := range getEnvVars(map[string]string{
+		"KAFKA_ROUTES":           "compliance.*=compliance,orders=missing,[=compliance,invalid",
+		"KAFKA_1_NAME":           "compliance",
+		"KAFKA_1_HOST":           "compliance-kafka:9092",
+		"KAFKA_1_SASL_MECHANISM": SASLPlain,
+		"KAFKA_2_NAME":           DefaultKafkaCluster,
+	}) {
+		os.Setenv(k, v)
+	}
+
+	_, err := NewConfig()
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validation errors, but got %v", err)
+	}
+
+	// the invalid routes, the missing SASL credentials and host, the reserved
+	// name, and the route to the missing cluster
+	if len(errs) != 6 {
+		t.Errorf("expected 6 validation errors, but got %d: %s", len(errs), errs)
+	}
+}
+
+func TestNewConfig_WithUnknownKafkaClusterSetting(t *testing.T) {
+	os.Args = nil
+	defer os.Clearenv()
+	for k, v := range getEnvVars(map[string]string{
+		"KAFKA_1_NAME":  "compliance",
+		"KAFKA_1_HOSTS": "compliance-kafka:9092",
+	}) {
+		os.Setenv(k, v)
+	}
+
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected an error for the unknown Kafka cluster setting, but got nil")
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/newrelic/go-agent/v3/newrelic"
 
 	"inviqa/kafka-outbox-relay/log"
 
+	"github.com/Shopify/sarama"
 	"github.com/alexflint/go-arg"
 )
 
@@ -17,8 +20,14 @@ const (
 	MySQL    DbDriver = "mysql"
 	Postgres DbDriver = "postgres"
 
-	defaultPublishAttempts = 3
-	outboxTable            = "kafka_outbox"
+	defaultPublishAttempts     = 3
+	defaultRetryAttempts       = 20
+	defaultStaleBatchTimeout   = time.Minute * 10
+	defaultRetryBaseDelay      = time.Second
+	defaultRetryMaxDelay       = time.Minute * 5
+	defaultSizeMetricsInterval = time.Second * 30
+	defaultSecretsPollInterval = time.Second * 10
+	outboxTable                = "kafka_outbox"
 )
 
 type DbDriver string
@@ -29,104 +38,268 @@ var supportedDbTypes = map[DbDriver]bool{
 }
 
 type args struct {
-	PollingDisabled      bool     `arg:"--polling-disabled,env:POLLING_DISABLED"`
-	SkipMigrations       bool     `arg:"--skip-migrations,env:SKIP_MIGRATIONS"`
-	DBHost               string   `arg:"--db-host,env:DB_HOST,required"`
-	DBPort               uint32   `arg:"--db-port,env:DB_PORT,required"`
-	DBUser               string   `arg:"--db-user,env:DB_USER,required"`
-	DBPass               string   `arg:"--db-pass,env:DB_PASS,required"`
-	DBNames              []string `arg:"--db-name,env:DB_NAME,required"`
-	DBDriver             DbDriver `arg:"--db-driver,env:DB_DRIVER,required"`
-	DBOutboxTable        string
-	KafkaHost            []string `arg:"--kafka-host,env:KAFKA_HOST"`
-	KafkaPublishAttempts int      `arg:"--kafka-publish-attempts,env:KAFKA_PUBLISH_ATTEMPTS"`
-	TLSEnable            bool     `arg:"--kafka-tls,env:TLS_ENABLE"`
-	TLSSkipVerifyPeer    bool     `arg:"--kafka-tls-verify-peer,env:TLS_SKIP_VERIFY_PEER"`
-	WriteConcurrency     int      `arg:"--write-concurrency,env:WRITE_CONCURRENCY"`
-	PollFrequencyMs      int      `arg:"--poll-frequency-ms,env:POLL_FREQUENCY_MS"`
-	RunCleanup           bool     `arg:"--cleanup,env:RUN_CLEANUP"`
-	RunOptimize          bool     `arg:"--optimize,env:RUN_OPTIMIZE"`
-	SidecarProxyUrl      string   `arg:"--sidecar-proxy-url,env:SIDECAR_PROXY_URL"`
-	BatchSize            int      `arg:"--batch-size,env:BATCH_SIZE"`
+	ConfigFile                 string        `arg:"--config-file,env:CONFIG_FILE"`
+	PrintConfig                bool          `arg:"--print-config,env:PRINT_CONFIG"`
+	PollingDisabled            bool          `arg:"--polling-disabled,env:POLLING_DISABLED"`
+	SkipMigrations             bool          `arg:"--skip-migrations,env:SKIP_MIGRATIONS"`
+	DBHost                     string        `arg:"--db-host,env:DB_HOST"`
+	DBPort                     uint32        `arg:"--db-port,env:DB_PORT"`
+	DBUser                     string        `arg:"--db-user,env:DB_USER"`
+	DBPass                     string        `arg:"--db-pass,env:DB_PASS"`
+	DBPassFile                 string        `arg:"--db-pass-file,env:DB_PASS_FILE"`
+	DBNames                    []string      `arg:"--db-name,env:DB_NAME"`
+	DBDriver                   DbDriver      `arg:"--db-driver,env:DB_DRIVER"`
+	DBOutboxTable              string        `arg:"--db-outbox-table,env:DB_OUTBOX_TABLE"`
+	DBTLSCAFile                string        `arg:"--db-tls-ca-file,env:DB_TLS_CA_FILE"`
+	DBTLSCertFile              string        `arg:"--db-tls-cert-file,env:DB_TLS_CERT_FILE"`
+	DBTLSKeyFile               string        `arg:"--db-tls-key-file,env:DB_TLS_KEY_FILE"`
+	KafkaHost                  []string      `arg:"--kafka-host,env:KAFKA_HOST"`
+	KafkaPublishAttempts       int           `arg:"--kafka-publish-attempts,env:KAFKA_PUBLISH_ATTEMPTS"`
+	KafkaRetryAttempts         int           `arg:"--kafka-retry-attempts,env:KAFKA_RETRY_ATTEMPTS"`
+	KafkaRetryBaseDelay        time.Duration `arg:"--kafka-retry-base-delay,env:KAFKA_RETRY_BASE_DELAY"`
+	KafkaRetryMaxDelay         time.Duration `arg:"--kafka-retry-max-delay,env:KAFKA_RETRY_MAX_DELAY"`
+	KafkaVersion               string        `arg:"--kafka-version,env:KAFKA_VERSION"`
+	KafkaCompression           string        `arg:"--kafka-compression,env:KAFKA_COMPRESSION"`
+	KafkaRequiredAcks          string        `arg:"--kafka-required-acks,env:KAFKA_REQUIRED_ACKS"`
+	KafkaIdempotent            bool          `arg:"--kafka-idempotent,env:KAFKA_IDEMPOTENT"`
+	KafkaMaxMessageBytes       int           `arg:"--kafka-max-message-bytes,env:KAFKA_MAX_MESSAGE_BYTES"`
+	KafkaFlushFrequency        time.Duration `arg:"--kafka-flush-frequency,env:KAFKA_FLUSH_FREQUENCY"`
+	KafkaFlushBytes            int           `arg:"--kafka-flush-bytes,env:KAFKA_FLUSH_BYTES"`
+	KafkaSASLMechanism         string        `arg:"--kafka-sasl-mechanism,env:KAFKA_SASL_MECHANISM"`
+	KafkaSASLUser              string        `arg:"--kafka-sasl-user,env:KAFKA_SASL_USER"`
+	KafkaSASLUserFile          string        `arg:"--kafka-sasl-user-file,env:KAFKA_SASL_USER_FILE"`
+	KafkaSASLPass              string        `arg:"--kafka-sasl-pass,env:KAFKA_SASL_PASS"`
+	KafkaSASLPassFile          string        `arg:"--kafka-sasl-pass-file,env:KAFKA_SASL_PASS_FILE"`
+	KafkaSASLOAuthToken        string        `arg:"--kafka-sasl-oauth-token,env:KAFKA_SASL_OAUTH_TOKEN"`
+	KafkaSASLOAuthTokenFile    string        `arg:"--kafka-sasl-oauth-token-file,env:KAFKA_SASL_OAUTH_TOKEN_FILE"`
+	TLSEnable                  bool          `arg:"--kafka-tls,env:TLS_ENABLE"`
+	TLSSkipVerifyPeer          bool          `arg:"--kafka-tls-verify-peer,env:TLS_SKIP_VERIFY_PEER"`
+	KafkaTLSCAFile             string        `arg:"--kafka-tls-ca-file,env:KAFKA_TLS_CA_FILE"`
+	KafkaTLSCertFile           string        `arg:"--kafka-tls-cert-file,env:KAFKA_TLS_CERT_FILE"`
+	KafkaTLSKeyFile            string        `arg:"--kafka-tls-key-file,env:KAFKA_TLS_KEY_FILE"`
+	KafkaRoutes                []string      `arg:"--kafka-routes,env:KAFKA_ROUTES"`
+	KafkaTopicPrefix           string        `arg:"--kafka-topic-prefix,env:KAFKA_TOPIC_PREFIX"`
+	KafkaTopicSuffix           string        `arg:"--kafka-topic-suffix,env:KAFKA_TOPIC_SUFFIX"`
+	KafkaTopicRewrites         []string      `arg:"--kafka-topic-rewrites,env:KAFKA_TOPIC_REWRITES"`
+	KafkaTopicMappingFile      string        `arg:"--kafka-topic-mapping-file,env:KAFKA_TOPIC_MAPPING_FILE"`
+	KafkaTopicBlock            []string      `arg:"--kafka-topic-block,env:KAFKA_TOPIC_BLOCK"`
+	KafkaSerializers           []string      `arg:"--kafka-serializers,env:KAFKA_SERIALIZERS"`
+	SchemaRegistryUrl          string        `arg:"--schema-registry-url,env:SCHEMA_REGISTRY_URL"`
+	SchemaRegistryUser         string        `arg:"--schema-registry-user,env:SCHEMA_REGISTRY_USER"`
+	SchemaRegistryPass         string        `arg:"--schema-registry-pass,env:SCHEMA_REGISTRY_PASS"`
+	SchemaRegistryCacheTTL     time.Duration `arg:"--schema-registry-cache-ttl,env:SCHEMA_REGISTRY_CACHE_TTL"`
+	KafkaRelayHeaders          []string      `arg:"--kafka-relay-headers,env:KAFKA_RELAY_HEADERS"`
+	KafkaPartitioner           string        `arg:"--kafka-partitioner,env:KAFKA_PARTITIONER"`
+	KafkaTopicPartitioners     []string      `arg:"--kafka-topic-partitioners,env:KAFKA_TOPIC_PARTITIONERS"`
+	WriteConcurrency           int           `arg:"--write-concurrency,env:WRITE_CONCURRENCY"`
+	PollFrequencyMs            int           `arg:"--poll-frequency-ms,env:POLL_FREQUENCY_MS"`
+	RunCleanup                 bool          `arg:"--cleanup,env:RUN_CLEANUP"`
+	RunOptimize                bool          `arg:"--optimize,env:RUN_OPTIMIZE"`
+	SidecarProxyUrl            string        `arg:"--sidecar-proxy-url,env:SIDECAR_PROXY_URL"`
+	BatchSize                  int           `arg:"--batch-size,env:BATCH_SIZE"`
+	KafkaTransactional         bool          `arg:"--kafka-transactional,env:KAFKA_TRANSACTIONAL"`
+	KafkaTransactionalIdPrefix string        `arg:"--kafka-transactional-id-prefix,env:KAFKA_TRANSACTIONAL_ID_PREFIX"`
+	KafkaDeadLetterTopic       string        `arg:"--kafka-dead-letter-topic,env:KAFKA_DEAD_LETTER_TOPIC"`
+	StaleBatchTimeout          time.Duration `arg:"--stale-batch-timeout,env:STALE_BATCH_TIMEOUT"`
+	RunRequeueErrored          bool          `arg:"--requeue-errored,env:RUN_REQUEUE_ERRORED"`
+	RequeueDryRun              bool          `arg:"--requeue-dry-run,env:REQUEUE_DRY_RUN"`
+	RequeueTopic               string        `arg:"--requeue-topic,env:REQUEUE_TOPIC"`
+	RequeueMinId               int64         `arg:"--requeue-min-id,env:REQUEUE_MIN_ID"`
+	RequeueMaxId               int64         `arg:"--requeue-max-id,env:REQUEUE_MAX_ID"`
+	RequeueCreatedAfter        time.Time     `arg:"--requeue-created-after,env:REQUEUE_CREATED_AFTER"`
+	RequeueCreatedBefore       time.Time     `arg:"--requeue-created-before,env:REQUEUE_CREATED_BEFORE"`
+	RequeueErrorReason         string        `arg:"--requeue-error-reason,env:REQUEUE_ERROR_REASON"`
+	SizeMetricsInterval        time.Duration `arg:"--size-metrics-interval,env:SIZE_METRICS_INTERVAL"`
+	SecretsPollInterval        time.Duration `arg:"--secrets-poll-interval,env:SECRETS_POLL_INTERVAL"`
 }
 
 type Database struct {
 	Host, User, Password, Name, OutboxTable string
-	Port                                    uint32
-	Driver                                  DbDriver
-	TLSSkipVerifyPeer                       bool
-	TLSEnable                               bool
+	// PasswordFile is the path to a file containing the password, which is
+	// read again whenever a new connection is made, so that the password can
+	// be rotated without restarting the relay.
+	PasswordFile      string
+	Port              uint32
+	Driver            DbDriver
+	TLSSkipVerifyPeer bool
+	TLSEnable         bool
+	TLSFiles          TLSFiles
+	// BatchSize and WriteConcurrency override the global settings for this
+	// database when they are greater than zero.
+	BatchSize        int
+	WriteConcurrency int
 }
 
 type Config struct {
-	PollingDisabled      bool
-	SkipMigrations       bool
-	DBs                  []Database
-	KafkaHost            []string
-	KafkaPublishAttempts int
-	TLSEnable            bool
-	TLSSkipVerifyPeer    bool
-	WriteConcurrency     int
-	PollFrequencyMs      int
-	RunCleanup           bool
-	RunOptimize          bool
-	SidecarProxyUrl      string
-	BatchSize            int
+	PrintConfig                bool
+	PollingDisabled            bool
+	SkipMigrations             bool
+	DBs                        []Database
+	KafkaHost                  []string
+	KafkaPublishAttempts       int
+	KafkaRetryAttempts         int
+	KafkaRetryBaseDelay        time.Duration
+	KafkaRetryMaxDelay         time.Duration
+	KafkaProducer              KafkaProducer
+	KafkaSASL                  SASL
+	TLSEnable                  bool
+	TLSSkipVerifyPeer          bool
+	KafkaTLSFiles              TLSFiles
+	KafkaClusters              []KafkaCluster
+	KafkaRoutes                TopicPatterns
+	KafkaTopics                TopicRewrite
+	KafkaSerializers           TopicPatterns
+	SchemaRegistry             SchemaRegistry
+	KafkaRelayHeaders          []string
+	KafkaPartitioning          Partitioning
+	WriteConcurrency           int
+	PollFrequencyMs            int
+	RunCleanup                 bool
+	RunOptimize                bool
+	SidecarProxyUrl            string
+	BatchSize                  int
+	StaleBatchTimeout          time.Duration
+	KafkaTransactional         bool
+	KafkaTransactionalIdPrefix string
+	KafkaDeadLetterTopic       string
+	RunRequeueErrored          bool
+	Requeue                    Requeue
+	SizeMetricsInterval        time.Duration
+	SecretsPollInterval        time.Duration
 }
 
+// Requeue holds the options for the requeue errored job. Any zero-valued
+// filter is ignored, so by default every errored message is requeued.
+type Requeue struct {
+	DryRun             bool
+	Topic              string
+	MinId              int64
+	MaxId              int64
+	CreatedAfter       time.Time
+	CreatedBefore      time.Time
+	ErrorReasonPattern string
+}
+
+// SASL holds the credentials used to authenticate with Kafka, when Mechanism
+// is not empty. Each credential can be read from a file instead, which is read
+// again whenever the Kafka producers are created, see Credentials.
+type SASL struct {
+	Mechanism      string
+	User           string
+	UserFile       string
+	Password       string
+	PasswordFile   string
+	OAuthToken     string
+	OAuthTokenFile string
+}
+
+// NewConfig creates the configuration from the env vars and command line
+// arguments, along with the optional config file. If the configuration is not
+// valid then a ValidationErrors is returned, listing every problem found.
 func NewConfig() (*Config, error) {
+	if err := loadConfigFile(configFilePath(os.Args)); err != nil {
+		return nil, err
+	}
+
 	a := &args{
-		KafkaPublishAttempts: defaultPublishAttempts,
-		DBOutboxTable:        outboxTable,
-		WriteConcurrency:     1,
-		PollFrequencyMs:      500,
-		BatchSize:            250,
+		KafkaPublishAttempts:   defaultPublishAttempts,
+		KafkaRetryAttempts:     defaultRetryAttempts,
+		DBOutboxTable:          outboxTable,
+		WriteConcurrency:       1,
+		PollFrequencyMs:        500,
+		BatchSize:              250,
+		StaleBatchTimeout:      defaultStaleBatchTimeout,
+		KafkaRetryBaseDelay:    defaultRetryBaseDelay,
+		KafkaRetryMaxDelay:     defaultRetryMaxDelay,
+		KafkaVersion:           defaultKafkaVersion,
+		KafkaCompression:       defaultKafkaCompression,
+		KafkaMaxMessageBytes:   sarama.NewConfig().Producer.MaxMessageBytes,
+		SizeMetricsInterval:    defaultSizeMetricsInterval,
+		SecretsPollInterval:    defaultSecretsPollInterval,
+		SchemaRegistryCacheTTL: defaultSchemaRegistryCacheTTL,
+		KafkaPartitioner:       defaultPartitioner,
 	}
 	arg.MustParse(a)
 
-	if !supportedDbTypes[a.DBDriver] {
-		return nil, fmt.Errorf("the DB_DRIVER provided (%s) is not supported", a.DBDriver)
+	var errs ValidationErrors
+	dbs, err := databasesConfig(a, os.Environ())
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		errs = append(errs, validateDatabases(dbs)...)
+		errs = append(errs, readPasswordFiles(dbs)...)
+	}
+	errs = append(errs, a.validate()...)
+	producer, producerErrs := a.kafkaProducer()
+	errs = append(errs, producerErrs...)
+	routes, routeErrs := kafkaRoutes(a.KafkaRoutes)
+	errs = append(errs, routeErrs...)
+	topics, topicErrs := a.topicRewrite()
+	errs = append(errs, topicErrs...)
+	serializers, serializerErrs := a.serializers()
+	errs = append(errs, serializerErrs...)
+	relayHeaders, relayHeaderErrs := a.relayHeaders()
+	errs = append(errs, relayHeaderErrs...)
+	partitioning, partitioningErrs := a.partitioning()
+	errs = append(errs, partitioningErrs...)
+	clusters, err := kafkaClustersConfig(a, os.Environ())
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		errs = append(errs, validateKafkaClusters(clusters, routes)...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
 	return &Config{
-		PollingDisabled:      a.PollingDisabled,
-		SkipMigrations:       a.SkipMigrations,
-		KafkaHost:            a.KafkaHost,
-		DBs:                  databasesConfig(a),
-		KafkaPublishAttempts: a.KafkaPublishAttempts,
-		TLSEnable:            a.TLSEnable,
-		TLSSkipVerifyPeer:    a.TLSSkipVerifyPeer,
-		WriteConcurrency:     a.WriteConcurrency,
-		PollFrequencyMs:      a.PollFrequencyMs,
-		RunCleanup:           a.RunCleanup,
-		RunOptimize:          a.RunOptimize,
-		SidecarProxyUrl:      a.SidecarProxyUrl,
-		BatchSize:            a.BatchSize,
+		PrintConfig:                a.PrintConfig,
+		PollingDisabled:            a.PollingDisabled,
+		SkipMigrations:             a.SkipMigrations,
+		KafkaHost:                  a.KafkaHost,
+		DBs:                        dbs,
+		KafkaPublishAttempts:       a.KafkaPublishAttempts,
+		KafkaRetryAttempts:         a.KafkaRetryAttempts,
+		KafkaRetryBaseDelay:        a.KafkaRetryBaseDelay,
+		KafkaRetryMaxDelay:         a.KafkaRetryMaxDelay,
+		KafkaProducer:              producer,
+		KafkaSASL:                  a.sasl(),
+		TLSEnable:                  a.TLSEnable,
+		TLSSkipVerifyPeer:          a.TLSSkipVerifyPeer,
+		KafkaTLSFiles:              a.kafkaTLSFiles(),
+		KafkaClusters:              clusters,
+		KafkaRoutes:                routes,
+		KafkaTopics:                topics,
+		KafkaSerializers:           serializers,
+		SchemaRegistry:             a.schemaRegistry(),
+		KafkaRelayHeaders:          relayHeaders,
+		KafkaPartitioning:          partitioning,
+		WriteConcurrency:           a.WriteConcurrency,
+		PollFrequencyMs:            a.PollFrequencyMs,
+		RunCleanup:                 a.RunCleanup,
+		RunOptimize:                a.RunOptimize,
+		SidecarProxyUrl:            a.SidecarProxyUrl,
+		BatchSize:                  a.BatchSize,
+		StaleBatchTimeout:          a.StaleBatchTimeout,
+		KafkaTransactional:         a.KafkaTransactional,
+		KafkaTransactionalIdPrefix: a.KafkaTransactionalIdPrefix,
+		KafkaDeadLetterTopic:       a.KafkaDeadLetterTopic,
+		RunRequeueErrored:          a.RunRequeueErrored,
+		Requeue: Requeue{
+			DryRun:             a.RequeueDryRun,
+			Topic:              a.RequeueTopic,
+			MinId:              a.RequeueMinId,
+			MaxId:              a.RequeueMaxId,
+			CreatedAfter:       a.RequeueCreatedAfter,
+			CreatedBefore:      a.RequeueCreatedBefore,
+			ErrorReasonPattern: a.RequeueErrorReason,
+		},
+		SizeMetricsInterval: a.SizeMetricsInterval,
+		SecretsPollInterval: a.SecretsPollInterval,
 	}, nil
 }
 
-// databasesConfig models database configuration, and currently creates a separate database config
-// for each database name that is provided from env
-// NOTE: In the future, we will likely expand this to allow multiple database connection details to
-// be provided via env vars too
-func databasesConfig(a *args) []Database {
-	var dbs []Database
-	for _, dbName := range a.DBNames {
-		dbs = append(dbs, Database{
-			Host:              a.DBHost,
-			Port:              a.DBPort,
-			User:              a.DBUser,
-			Password:          a.DBPass,
-			Name:              dbName,
-			Driver:            a.DBDriver,
-			OutboxTable:       a.DBOutboxTable,
-			TLSEnable:         a.TLSEnable,
-			TLSSkipVerifyPeer: a.TLSSkipVerifyPeer,
-		})
-	}
-	return dbs
-}
-
 func (c *Config) GetPollIntervalDurationInMs() time.Duration {
 	return time.Duration(c.PollFrequencyMs) * time.Millisecond
 }
@@ -136,7 +309,9 @@ func (d Database) GetDSN() string {
 	case MySQL:
 		tls := "false"
 		if d.TLSEnable {
-			if d.TLSSkipVerifyPeer {
+			if d.TLSFiles.Configured() {
+				tls = url.QueryEscape(d.MySQLTLSConfigName())
+			} else if d.TLSSkipVerifyPeer {
 				tls = "skip-verify"
 			} else {
 				tls = "true"
@@ -152,45 +327,130 @@ func (d Database) GetDSN() string {
 				sslMode = "verify-full"
 			}
 		}
-		return fmt.Sprintf("%s://%s@%s:%d/%s?sslmode=%s", d.Driver, url.UserPassword(d.User, d.Password), d.Host, d.Port, d.Name, sslMode)
+		dsn := fmt.Sprintf("%s://%s@%s:%d/%s?sslmode=%s", d.Driver, url.UserPassword(d.User, d.Password), d.Host, d.Port, d.Name, sslMode)
+		if d.TLSEnable {
+			dsn += d.TLSFiles.postgresParams()
+		}
+		return dsn
 	default:
 		log.Logger.Fatalf("the DB driver configured (%s) is not supported", d.Driver)
 		return ""
 	}
 }
 
+// MySQLTLSConfigName returns the name that the database's TLS config is
+// registered with in the MySQL driver, when it has TLS files.
+func (d Database) MySQLTLSConfigName() string {
+	return "kafka-outbox-relay-" + d.Name
+}
+
+// BatchSizeFor returns the batch size to use for the database, which may
+// override the global BATCH_SIZE.
+func (c *Config) BatchSizeFor(d Database) int {
+	if d.BatchSize > 0 {
+		return d.BatchSize
+	}
+
+	return c.BatchSize
+}
+
+// WriteConcurrencyFor returns the number of workers publishing messages from
+// the database, which may override the global WRITE_CONCURRENCY.
+func (c *Config) WriteConcurrencyFor(d Database) int {
+	if d.WriteConcurrency > 0 {
+		return d.WriteConcurrency
+	}
+
+	return c.WriteConcurrency
+}
+
 func (c *Config) GetDependencySystemAddresses() []string {
-	return c.KafkaHost
+	var addrs []string
+	for _, cluster := range c.AllKafkaClusters() {
+		addrs = append(addrs, cluster.Host...)
+	}
+
+	return addrs
+}
+
+// KafkaClusterAddresses returns the hosts of each Kafka cluster, keyed by the
+// cluster name.
+func (c *Config) KafkaClusterAddresses() map[string][]string {
+	addrs := map[string][]string{}
+	for _, cluster := range c.AllKafkaClusters() {
+		addrs[cluster.Name] = cluster.Host
+	}
+
+	return addrs
 }
 
 func (c Config) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]any{
-		"PollingDisabled":      c.PollingDisabled,
-		"SkipMigrations":       c.SkipMigrations,
-		"Databases":            c.DBs,
-		"KafkaHost":            c.KafkaHost,
-		"KafkaPublishAttempts": c.KafkaPublishAttempts,
-		"TLSEnable":            c.TLSEnable,
-		"TLSSkipVerifyPeer":    c.TLSSkipVerifyPeer,
-		"WriteConcurrency":     c.WriteConcurrency,
-		"PollFrequencyMs":      c.PollFrequencyMs,
-		"RunCleanup":           c.RunCleanup,
-		"RunOptimize":          c.RunOptimize,
-		"SidecarProxyUrl":      c.SidecarProxyUrl,
-		"BatchSize":            c.BatchSize,
+		"PrintConfig":                c.PrintConfig,
+		"PollingDisabled":            c.PollingDisabled,
+		"SkipMigrations":             c.SkipMigrations,
+		"Databases":                  c.DBs,
+		"KafkaHost":                  c.KafkaHost,
+		"KafkaPublishAttempts":       c.KafkaPublishAttempts,
+		"KafkaRetryAttempts":         c.KafkaRetryAttempts,
+		"KafkaRetryBaseDelay":        c.KafkaRetryBaseDelay.String(),
+		"KafkaRetryMaxDelay":         c.KafkaRetryMaxDelay.String(),
+		"KafkaProducer":              c.KafkaProducer,
+		"KafkaSASL":                  c.KafkaSASL,
+		"TLSEnable":                  c.TLSEnable,
+		"TLSSkipVerifyPeer":          c.TLSSkipVerifyPeer,
+		"KafkaTLSFiles":              c.KafkaTLSFiles,
+		"KafkaClusters":              c.KafkaClusters,
+		"KafkaRoutes":                c.KafkaRoutes,
+		"KafkaTopics":                c.KafkaTopics,
+		"KafkaSerializers":           c.KafkaSerializers,
+		"SchemaRegistry":             c.SchemaRegistry,
+		"KafkaRelayHeaders":          c.KafkaRelayHeaders,
+		"KafkaPartitioning":          c.KafkaPartitioning,
+		"WriteConcurrency":           c.WriteConcurrency,
+		"PollFrequencyMs":            c.PollFrequencyMs,
+		"RunCleanup":                 c.RunCleanup,
+		"RunOptimize":                c.RunOptimize,
+		"SidecarProxyUrl":            c.SidecarProxyUrl,
+		"BatchSize":                  c.BatchSize,
+		"StaleBatchTimeout":          c.StaleBatchTimeout.String(),
+		"KafkaTransactional":         c.KafkaTransactional,
+		"KafkaTransactionalIdPrefix": c.KafkaTransactionalIdPrefix,
+		"KafkaDeadLetterTopic":       c.KafkaDeadLetterTopic,
+		"RunRequeueErrored":          c.RunRequeueErrored,
+		"Requeue":                    c.Requeue,
+		"SizeMetricsInterval":        c.SizeMetricsInterval.String(),
+		"SecretsPollInterval":        c.SecretsPollInterval.String(),
 	})
 }
 
 func (d Database) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]any{
+		"Host":              d.Host,
 		"Port":              d.Port,
 		"User":              d.User,
 		"Pass":              "xxxxx",
+		"PassFile":          d.PasswordFile,
 		"Name":              d.Name,
 		"Driver":            d.Driver,
 		"OutboxTable":       d.OutboxTable,
 		"TLSEnable":         d.TLSEnable,
 		"TLSSkipVerifyPeer": d.TLSSkipVerifyPeer,
+		"TLSFiles":          d.TLSFiles,
+		"BatchSize":         d.BatchSize,
+		"WriteConcurrency":  d.WriteConcurrency,
+	})
+}
+
+func (s SASL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"Mechanism":      s.Mechanism,
+		"User":           s.User,
+		"UserFile":       s.UserFile,
+		"Pass":           "xxxxx",
+		"PassFile":       s.PasswordFile,
+		"OAuthToken":     "xxxxx",
+		"OAuthTokenFile": s.OAuthTokenFile,
 	})
 }
 
@@ -202,6 +462,22 @@ func (d DbDriver) Postgres() bool {
 	return d == Postgres
 }
 
+// QuoteIdentifier quotes each part of a, possibly schema qualified, identifier
+// such as a table name, e.g. events.kafka_outbox, for use in an SQL query.
+func (d DbDriver) QuoteIdentifier(name string) string {
+	quote := `"`
+	if d.MySQL() {
+		quote = "`"
+	}
+
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = quote + strings.ReplaceAll(p, quote, quote+quote) + quote
+	}
+
+	return strings.Join(parts, ".")
+}
+
 func (d DbDriver) String() string {
 	return string(d)
 }
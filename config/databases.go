@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// indexedDbVar matches the env vars used to configure each database
+// independently, e.g. DB_1_HOST or DB_2_OUTBOX_TABLE.
+var indexedDbVar = regexp.MustCompile(`^DB_(\d+)_([A-Z_]+)$`)
+
+// outboxTableName matches a table name, optionally qualified by its schema.
+var outboxTableName = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*\.)?[A-Za-z_][A-Za-z0-9_]*$`)
+
+// databasesConfig models database configuration. If any indexed DB_<n>_* env
+// vars are provided then a separate database config is created for each index,
+// using the shared DB_* values for any settings that are not overridden.
+// Otherwise, a database config is created for each name in DB_NAME, all of
+// which share the same connection details.
+func databasesConfig(a *args, environ []string) ([]Database, error) {
+	indexed, err := indexedEnv(environ, indexedDbVar)
+	if err != nil {
+		return nil, err
+	}
+
+	var dbs []Database
+	if len(indexed) == 0 {
+		for _, dbName := range a.DBNames {
+			dbs = append(dbs, sharedDatabaseConfig(a, dbName))
+		}
+	} else {
+		if len(a.DBNames) > 0 {
+			return nil, fmt.Errorf("DB_NAME cannot be used together with the indexed DB_<n>_NAME env vars")
+		}
+
+		dbs, err = indexedDatabasesConfig(a, indexed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dbs, nil
+}
+
+func sharedDatabaseConfig(a *args, dbName string) Database {
+	return Database{
+		Host:              a.DBHost,
+		Port:              a.DBPort,
+		User:              a.DBUser,
+		Password:          a.DBPass,
+		PasswordFile:      a.DBPassFile,
+		Name:              dbName,
+		Driver:            a.DBDriver,
+		OutboxTable:       a.DBOutboxTable,
+		TLSEnable:         a.TLSEnable,
+		TLSSkipVerifyPeer: a.TLSSkipVerifyPeer,
+		TLSFiles: TLSFiles{
+			CAFile:   a.DBTLSCAFile,
+			CertFile: a.DBTLSCertFile,
+			KeyFile:  a.DBTLSKeyFile,
+		},
+	}
+}
+
+func indexedDatabasesConfig(a *args, indexed map[int]map[string]string) ([]Database, error) {
+	indices := make([]int, 0, len(indexed))
+	for i := range indexed {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+
+	dbs := make([]Database, 0, len(indices))
+	for _, i := range indices {
+		if _, ok := indexed[i]["PASS_FILE"]; ok && indexed[i]["PASS"] != "" {
+			return nil, fmt.Errorf("DB_%d_PASS and DB_%d_PASS_FILE cannot both be provided", i, i)
+		}
+
+		db := sharedDatabaseConfig(a, "")
+		for key, val := range indexed[i] {
+			if err := db.set(key, val); err != nil {
+				return nil, fmt.Errorf("the DB_%d_%s provided (%s) is not valid: %w", i, key, val, err)
+			}
+		}
+		dbs = append(dbs, db)
+	}
+
+	return dbs, nil
+}
+
+// indexedEnv groups the indexed env vars matched by re, e.g. DB_<n>_*, by their
+// index.
+func indexedEnv(environ []string, re *regexp.Regexp) (map[int]map[string]string, error) {
+	indexed := map[int]map[string]string{}
+	for _, kv := range environ {
+		name, val, _ := strings.Cut(kv, "=")
+		m := re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+
+		i, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("the index in %s is not valid: %w", name, err)
+		}
+
+		if indexed[i] == nil {
+			indexed[i] = map[string]string{}
+		}
+		indexed[i][m[2]] = val
+	}
+
+	return indexed, nil
+}
+
+// set overrides the database setting with the given DB_<n>_* env var suffix.
+func (d *Database) set(key, val string) error {
+	var err error
+	switch key {
+	case "HOST":
+		d.Host = val
+	case "PORT":
+		var port uint64
+		port, err = strconv.ParseUint(val, 10, 32)
+		d.Port = uint32(port)
+	case "USER":
+		d.User = val
+	case "PASS":
+		d.Password = val
+		d.PasswordFile = ""
+	case "PASS_FILE":
+		d.PasswordFile = val
+		d.Password = ""
+	case "NAME":
+		d.Name = val
+	case "DRIVER":
+		d.Driver = DbDriver(val)
+	case "OUTBOX_TABLE":
+		d.OutboxTable = val
+	case "TLS_ENABLE":
+		d.TLSEnable, err = strconv.ParseBool(val)
+	case "TLS_SKIP_VERIFY_PEER":
+		d.TLSSkipVerifyPeer, err = strconv.ParseBool(val)
+	case "TLS_CA_FILE":
+		d.TLSFiles.CAFile = val
+	case "TLS_CERT_FILE":
+		d.TLSFiles.CertFile = val
+	case "TLS_KEY_FILE":
+		d.TLSFiles.KeyFile = val
+	case "BATCH_SIZE":
+		d.BatchSize, err = strconv.Atoi(val)
+	case "WRITE_CONCURRENCY":
+		d.WriteConcurrency, err = strconv.Atoi(val)
+	default:
+		err = fmt.Errorf("unknown database setting")
+	}
+
+	return err
+}
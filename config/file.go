@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envTag extracts the env var name from an args struct tag.
+var envTag = regexp.MustCompile(`env:([A-Z0-9_]+)`)
+
+// configFilePath returns the path to the config file given in the command
+// line arguments, e.g. os.Args, or the CONFIG_FILE env var. This has to be
+// found before the rest of the arguments are parsed, so that the file can be
+// loaded first.
+func configFilePath(cmdArgs []string) string {
+	for i := 1; i < len(cmdArgs); i++ {
+		if strings.HasPrefix(cmdArgs[i], "--config-file=") {
+			return strings.TrimPrefix(cmdArgs[i], "--config-file=")
+		}
+		if cmdArgs[i] == "--config-file" && i+1 < len(cmdArgs) {
+			return cmdArgs[i+1]
+		}
+	}
+
+	return os.Getenv("CONFIG_FILE")
+}
+
+// loadConfigFile reads the YAML config file at path, which contains the same
+// settings as the env vars, e.g. DB_HOST, and sets an env var for each setting
+// that is not already set, so that env vars always take precedence over the
+// file. Lists are joined with commas, as they are in env vars.
+func loadConfigFile(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read the config file: %w", err)
+	}
+
+	var settings map[string]yaml.Node
+	if err := yaml.Unmarshal(b, &settings); err != nil {
+		return fmt.Errorf("unable to parse the config file %s: %w", path, err)
+	}
+
+	known := knownEnvVars()
+	var errs ValidationErrors
+	for name, node := range settings {
+		if !known[name] && !indexedDbVar.MatchString(name) && !indexedKafkaVar.MatchString(name) {
+			errs = append(errs, fmt.Errorf("the config file contains an unknown setting %s", name))
+			continue
+		}
+
+		val, err := settingValue(node)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("the config file setting %s is not valid: %w", name, err))
+			continue
+		}
+
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, val)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func settingValue(node yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.SequenceNode:
+		vals := make([]string, 0, len(node.Content))
+		for _, n := range node.Content {
+			if n.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("lists can only contain single values")
+			}
+			vals = append(vals, n.Value)
+		}
+		return strings.Join(vals, ","), nil
+	default:
+		return "", fmt.Errorf("it must be a single value or a list")
+	}
+}
+
+// knownEnvVars returns the name of every env var that can be used to configure
+// the relay.
+func knownEnvVars() map[string]bool {
+	known := map[string]bool{}
+	t := reflect.TypeOf(args{})
+	for i := 0; i < t.NumField(); i++ {
+		if m := envTag.FindStringSubmatch(t.Field(i).Tag.Get("arg")); m != nil {
+			known[m[1]] = true
+		}
+	}
+
+	return known
+}
@@ -0,0 +1,43 @@
+package config
+
+import "fmt"
+
+// The headers that the relay can add to each message it publishes, which are
+// enabled by listing them in KAFKA_RELAY_HEADERS.
+const (
+	RelayHeaderOutboxId  = "outbox-id"
+	RelayHeaderDatabase  = "database"
+	RelayHeaderCreatedAt = "created-at"
+	RelayHeaderBatchId   = "batch-id"
+	RelayHeaderAttempt   = "attempt"
+	RelayHeaderMessageId = "message-id"
+	// RelayHeaderTraceContext adds the distributed trace headers, e.g.
+	// traceparent, rather than a single header.
+	RelayHeaderTraceContext = "trace-context"
+)
+
+var supportedRelayHeaders = map[string]bool{
+	RelayHeaderOutboxId:     true,
+	RelayHeaderDatabase:     true,
+	RelayHeaderCreatedAt:    true,
+	RelayHeaderBatchId:      true,
+	RelayHeaderAttempt:      true,
+	RelayHeaderMessageId:    true,
+	RelayHeaderTraceContext: true,
+}
+
+// relayHeaders returns the KAFKA_RELAY_HEADERS, along with an error for every
+// header that is not supported.
+func (a *args) relayHeaders() ([]string, []error) {
+	var errs []error
+	var headers []string
+	for _, h := range a.KafkaRelayHeaders {
+		if !supportedRelayHeaders[h] {
+			errs = append(errs, fmt.Errorf("the KAFKA_RELAY_HEADERS entry provided (%s) must be one of outbox-id, database, created-at, batch-id, attempt, message-id or trace-context", h))
+			continue
+		}
+		headers = append(headers, h)
+	}
+
+	return headers, errs
+}
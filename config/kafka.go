@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+const (
+	defaultKafkaVersion     = "2.4.0"
+	defaultKafkaCompression = "gzip"
+)
+
+var requiredAcks = map[string]sarama.RequiredAcks{
+	"none":   sarama.NoResponse,
+	"leader": sarama.WaitForLocal,
+	"all":    sarama.WaitForAll,
+}
+
+// KafkaProducer holds the settings used to tune the Kafka producers.
+type KafkaProducer struct {
+	Version         sarama.KafkaVersion
+	Compression     sarama.CompressionCodec
+	RequiredAcks    sarama.RequiredAcks
+	Idempotent      bool
+	MaxMessageBytes int
+	// FlushFrequency and FlushBytes control how long the producer waits to
+	// group messages together before sending them, when greater than zero.
+	FlushFrequency time.Duration
+	FlushBytes     int
+}
+
+// kafkaProducer parses the Kafka producer settings, returning every setting
+// that is not valid, or cannot be used with the other settings.
+func (a *args) kafkaProducer() (KafkaProducer, []error) {
+	var errs []error
+	add := func(format string, v ...any) {
+		errs = append(errs, fmt.Errorf(format, v...))
+	}
+
+	p := KafkaProducer{
+		Idempotent:      a.KafkaIdempotent,
+		MaxMessageBytes: a.KafkaMaxMessageBytes,
+		FlushFrequency:  a.KafkaFlushFrequency,
+		FlushBytes:      a.KafkaFlushBytes,
+	}
+
+	var err error
+	if p.Version, err = sarama.ParseKafkaVersion(a.KafkaVersion); err != nil {
+		add("the KAFKA_VERSION provided (%s) is not a valid Kafka version", a.KafkaVersion)
+	}
+
+	if err = p.Compression.UnmarshalText([]byte(a.KafkaCompression)); err != nil {
+		add("the KAFKA_COMPRESSION provided (%s) must be one of none, gzip, snappy, lz4 or zstd", a.KafkaCompression)
+	}
+
+	// an idempotent or transactional producer must wait for all in-sync
+	// replicas, so that is the default for them
+	idempotent := a.KafkaIdempotent || a.KafkaTransactional
+	switch acks, ok := requiredAcks[a.KafkaRequiredAcks]; {
+	case a.KafkaRequiredAcks == "" && idempotent:
+		p.RequiredAcks = sarama.WaitForAll
+	case a.KafkaRequiredAcks == "":
+		p.RequiredAcks = sarama.WaitForLocal
+	case !ok:
+		add("the KAFKA_REQUIRED_ACKS provided (%s) must be one of none, leader or all", a.KafkaRequiredAcks)
+	case idempotent && acks != sarama.WaitForAll:
+		add("KAFKA_REQUIRED_ACKS must be all when KAFKA_IDEMPOTENT or KAFKA_TRANSACTIONAL is enabled")
+	default:
+		p.RequiredAcks = acks
+	}
+
+	if idempotent && !p.Version.IsAtLeast(sarama.V0_11_0_0) {
+		add("KAFKA_IDEMPOTENT and KAFKA_TRANSACTIONAL require a KAFKA_VERSION of at least 0.11.0")
+	}
+
+	if p.Compression == sarama.CompressionZSTD && !p.Version.IsAtLeast(sarama.V2_1_0_0) {
+		add("zstd compression requires a KAFKA_VERSION of at least 2.1.0")
+	}
+
+	if p.MaxMessageBytes < 1 {
+		add("the KAFKA_MAX_MESSAGE_BYTES provided (%d) must be at least 1", p.MaxMessageBytes)
+	}
+
+	if p.FlushFrequency < 0 || p.FlushBytes < 0 {
+		add("KAFKA_FLUSH_FREQUENCY and KAFKA_FLUSH_BYTES cannot be negative")
+	}
+
+	return p, errs
+}
+
+// Apply configures the producer in cfg with the settings.
+func (p KafkaProducer) Apply(cfg *sarama.Config) {
+	cfg.Version = p.Version
+	cfg.Producer.Compression = p.Compression
+	cfg.Producer.RequiredAcks = p.RequiredAcks
+	cfg.Producer.Idempotent = p.Idempotent
+	cfg.Producer.MaxMessageBytes = p.MaxMessageBytes
+	cfg.Producer.Flush.Frequency = p.FlushFrequency
+	cfg.Producer.Flush.Bytes = p.FlushBytes
+}
+
+func (p KafkaProducer) MarshalJSON() ([]byte, error) {
+	acks := ""
+	for name, a := range requiredAcks {
+		if a == p.RequiredAcks {
+			acks = name
+		}
+	}
+
+	return json.Marshal(map[string]any{
+		"Version":         p.Version.String(),
+		"Compression":     p.Compression.String(),
+		"RequiredAcks":    acks,
+		"Idempotent":      p.Idempotent,
+		"MaxMessageBytes": p.MaxMessageBytes,
+		"FlushFrequency":  p.FlushFrequency.String(),
+		"FlushBytes":      p.FlushBytes,
+	})
+}
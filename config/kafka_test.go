@@ -0,0 +1,68 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestKafkaProducer_Apply(t *testing.T) {
+	a := &args{
+		KafkaVersion:         "3.3.0",
+		KafkaCompression:     "lz4",
+		KafkaIdempotent:      true,
+		KafkaMaxMessageBytes: 2000000,
+		KafkaFlushFrequency:  time.Millisecond * 10,
+		KafkaFlushBytes:      1024,
+	}
+	p, errs := a.kafkaProducer()
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	cfg := sarama.NewConfig()
+	cfg.Net.MaxOpenRequests = 1
+	p.Apply(cfg)
+
+	if cfg.Version != sarama.V3_3_0_0 {
+		t.Errorf("expected Kafka version 3.3.0, but got %s", cfg.Version)
+	}
+
+	if cfg.Producer.Compression != sarama.CompressionLZ4 {
+		t.Errorf("expected lz4 compression, but got %s", cfg.Producer.Compression)
+	}
+
+	if cfg.Producer.RequiredAcks != sarama.WaitForAll || !cfg.Producer.Idempotent {
+		t.Error("expected an idempotent producer that waits for all in-sync replicas")
+	}
+
+	if cfg.Producer.MaxMessageBytes != 2000000 || cfg.Producer.Flush.Frequency != time.Millisecond*10 || cfg.Producer.Flush.Bytes != 1024 {
+		t.Errorf("expected the message size and flush settings to be applied, but got %+v", cfg.Producer)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected a valid producer config, but got error: %s", err)
+	}
+}
+
+func TestKafkaProducer_MarshalJSON(t *testing.T) {
+	b, err := json.Marshal(KafkaProducer{
+		Version:      sarama.V2_4_0_0,
+		Compression:  sarama.CompressionSnappy,
+		RequiredAcks: sarama.WaitForAll,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["Version"] != "2.4.0" || got["Compression"] != "snappy" || got["RequiredAcks"] != "all" {
+		t.Errorf("expected the settings to be marshalled by name, but got %s", b)
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+)
+
+// The strategies used to choose the partition for each message that is not
+// published to an explicit partition.
+const (
+	PartitionerFNV        = "fnv"
+	PartitionerMurmur2    = "murmur2"
+	PartitionerCRC32      = "crc32"
+	PartitionerRoundRobin = "round-robin"
+
+	defaultPartitioner = PartitionerFNV
+)
+
+var supportedPartitioners = map[string]bool{
+	PartitionerFNV:        true,
+	PartitionerMurmur2:    true,
+	PartitionerCRC32:      true,
+	PartitionerRoundRobin: true,
+}
+
+// Partitioning holds the partitioning strategy used for each topic, which is
+// the strategy of the first of Topics whose pattern matches the topic, or the
+// Default strategy if none of them do.
+type Partitioning struct {
+	Default string
+	Topics  TopicPatterns
+}
+
+// Strategy returns the partitioning strategy for the topic.
+func (p Partitioning) Strategy(topic string) string {
+	if strategy, ok := p.Topics.For(topic); ok {
+		return strategy
+	}
+
+	if p.Default == "" {
+		return defaultPartitioner
+	}
+
+	return p.Default
+}
+
+// partitioning parses the KAFKA_PARTITIONER, and the KAFKA_TOPIC_PARTITIONERS
+// in the form <topic pattern>=<strategy>, returning every entry that is not
+// valid.
+func (a *args) partitioning() (Partitioning, []error) {
+	var errs []error
+	add := func(format string, v ...any) {
+		errs = append(errs, fmt.Errorf(format, v...))
+	}
+
+	p := Partitioning{Default: a.KafkaPartitioner}
+	if !supportedPartitioners[a.KafkaPartitioner] {
+		add("the KAFKA_PARTITIONER provided (%s) must be one of fnv, murmur2, crc32 or round-robin", a.KafkaPartitioner)
+	}
+
+	topics, topicErrs := parseTopicPatterns("KAFKA_TOPIC_PARTITIONERS", "strategy", "orders.*=murmur2", a.KafkaTopicPartitioners, func(strategy string) error {
+		if !supportedPartitioners[strategy] {
+			return fmt.Errorf("the KAFKA_TOPIC_PARTITIONERS strategy provided (%s) must be one of fnv, murmur2, crc32 or round-robin", strategy)
+		}
+		return nil
+	})
+	p.Topics = topics
+
+	return p, append(errs, topicErrs...)
+}
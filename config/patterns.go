@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// TopicPattern maps the topics matching Pattern, e.g. "orders.*", to Value,
+// such as a partitioning strategy, a serializer format or a Kafka cluster.
+type TopicPattern struct {
+	Pattern string
+	Value   string
+}
+
+// TopicPatterns are matched against each topic in order, so that the first
+// pattern to match a topic wins.
+type TopicPatterns []TopicPattern
+
+// For returns the value of the first pattern that matches the topic, and
+// whether any of them did.
+func (p TopicPatterns) For(topic string) (string, bool) {
+	for _, tp := range p {
+		if ok, _ := path.Match(tp.Pattern, topic); ok {
+			return tp.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// parseTopicPatterns parses the entries of the env var, in the form
+// <topic pattern>=<value>, where the placeholder and example describe the
+// value in the error for any entry that is not in that form. Each value is
+// also checked with validate, when it is not nil, and any entry that is not
+// valid is left out of the patterns that are returned.
+func parseTopicPatterns(env, placeholder, example string, entries []string, validate func(value string) error) (TopicPatterns, []error) {
+	var errs []error
+	var patterns TopicPatterns
+	for _, entry := range entries {
+		pattern, value, ok := strings.Cut(entry, "=")
+		if _, err := path.Match(pattern, ""); !ok || pattern == "" || value == "" || err != nil {
+			errs = append(errs, fmt.Errorf("the %s entry provided (%s) must be in the form <topic pattern>=<%s>, e.g. %s", env, entry, placeholder, example))
+			continue
+		}
+		if validate != nil {
+			if err := validate(value); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		patterns = append(patterns, TopicPattern{Pattern: pattern, Value: value})
+	}
+
+	return patterns, errs
+}
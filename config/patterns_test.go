@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseTopicPatterns(t *testing.T) {
+	validate := func(value string) error {
+		if value == "unknown" {
+			return errors.New("unknown value")
+		}
+		return nil
+	}
+
+	patterns, errs := parseTopicPatterns("KAFKA_ROUTES", "cluster", "compliance.*=compliance", []string{
+		"compliance.*=compliance",
+		"orders",
+		"=compliance",
+		"orders=",
+		"[orders=compliance",
+		"audit=unknown",
+		"*=default",
+	}, validate)
+
+	exp := TopicPatterns{
+		{Pattern: "compliance.*", Value: "compliance"},
+		{Pattern: "*", Value: "default"},
+	}
+	if diff := deep.Equal(exp, patterns); diff != nil {
+		t.Error(diff)
+	}
+
+	if len(errs) != 5 {
+		t.Errorf("expected an error for each of the 5 invalid entries, but got %d: %v", len(errs), errs)
+	}
+}
+
+func TestTopicPatterns_For(t *testing.T) {
+	p := TopicPatterns{
+		{Pattern: "orders.legacy", Value: "fnv"},
+		{Pattern: "orders.*", Value: "crc32"},
+	}
+
+	tests := map[string]string{
+		"orders.legacy":  "fnv",
+		"orders.created": "crc32",
+	}
+	for topic, exp := range tests {
+		if got, ok := p.For(topic); !ok || got != exp {
+			t.Errorf("expected %s for the %s topic, but got %s", exp, topic, got)
+		}
+	}
+
+	if got, ok := p.For("customers"); ok {
+		t.Errorf("expected no value for the customers topic, but got %s", got)
+	}
+}
+
+func TestPartitioning_Strategy(t *testing.T) {
+	p := Partitioning{
+		Default: PartitionerMurmur2,
+		Topics:  TopicPatterns{{Pattern: "orders.*", Value: PartitionerCRC32}},
+	}
+
+	if got := p.Strategy("orders.created"); got != PartitionerCRC32 {
+		t.Errorf("expected the crc32 strategy for the orders.created topic, but got %s", got)
+	}
+
+	if got := p.Strategy("customers"); got != PartitionerMurmur2 {
+		t.Errorf("expected the default murmur2 strategy for the customers topic, but got %s", got)
+	}
+
+	if got := (Partitioning{}).Strategy("orders"); got != PartitionerFNV {
+		t.Errorf("expected the fnv strategy by default, but got %s", got)
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+)
+
+// The SASL mechanisms that can be used to authenticate with Kafka.
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+	SASLOAuthBearer = "OAUTHBEARER"
+)
+
+var supportedSASLMechanisms = map[string]bool{
+	SASLPlain:       true,
+	SASLScramSHA256: true,
+	SASLScramSHA512: true,
+	SASLOAuthBearer: true,
+}
+
+func (a *args) sasl() SASL {
+	return SASL{
+		Mechanism:      a.KafkaSASLMechanism,
+		User:           a.KafkaSASLUser,
+		UserFile:       a.KafkaSASLUserFile,
+		Password:       a.KafkaSASLPass,
+		PasswordFile:   a.KafkaSASLPassFile,
+		OAuthToken:     a.KafkaSASLOAuthToken,
+		OAuthTokenFile: a.KafkaSASLOAuthTokenFile,
+	}
+}
+
+// Enabled returns whether SASL should be used to authenticate with Kafka.
+func (s SASL) Enabled() bool {
+	return s.Mechanism != ""
+}
+
+// Credentials returns the user and password, reading them from their files if
+// they were provided as files.
+func (s SASL) Credentials() (user, pass string, err error) {
+	user, pass = s.User, s.Password
+	if s.UserFile != "" {
+		if user, err = ReadSecretFile(s.UserFile); err != nil {
+			return "", "", err
+		}
+	}
+	if s.PasswordFile != "" {
+		if pass, err = ReadSecretFile(s.PasswordFile); err != nil {
+			return "", "", err
+		}
+	}
+
+	return user, pass, nil
+}
+
+// Token returns the OAUTHBEARER token, reading it from its file if it was
+// provided as a file, so that it is read again each time it is needed.
+func (s SASL) Token() (string, error) {
+	if s.OAuthTokenFile != "" {
+		return ReadSecretFile(s.OAuthTokenFile)
+	}
+
+	return s.OAuthToken, nil
+}
+
+// CredentialFiles returns the files that the user and password are read from,
+// which should be watched for changes. The OAUTHBEARER token file is not
+// included, because it is read again each time a token is needed.
+func (s SASL) CredentialFiles() []string {
+	var files []string
+	for _, f := range []string{s.UserFile, s.PasswordFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+
+	return files
+}
+
+func (s SASL) validate() []error {
+	var errs []error
+	add := func(format string, v ...any) {
+		errs = append(errs, fmt.Errorf(format, v...))
+	}
+
+	for _, setting := range []struct{ name, val, file string }{
+		{"KAFKA_SASL_USER", s.User, s.UserFile},
+		{"KAFKA_SASL_PASS", s.Password, s.PasswordFile},
+		{"KAFKA_SASL_OAUTH_TOKEN", s.OAuthToken, s.OAuthTokenFile},
+	} {
+		if setting.val != "" && setting.file != "" {
+			add("%s and %s_FILE cannot both be provided", setting.name, setting.name)
+		}
+	}
+
+	switch {
+	case !s.Enabled():
+		if s.User != "" || s.UserFile != "" || s.Password != "" || s.PasswordFile != "" || s.OAuthToken != "" || s.OAuthTokenFile != "" {
+			add("a KAFKA_SASL_MECHANISM must be provided to authenticate with Kafka using SASL")
+		}
+	case !supportedSASLMechanisms[s.Mechanism]:
+		add("the KAFKA_SASL_MECHANISM provided (%s) is not supported", s.Mechanism)
+	case s.Mechanism == SASLOAuthBearer:
+		if s.OAuthToken == "" && s.OAuthTokenFile == "" {
+			add("a KAFKA_SASL_OAUTH_TOKEN or KAFKA_SASL_OAUTH_TOKEN_FILE must be provided for the %s mechanism", s.Mechanism)
+		}
+		if s.OAuthTokenFile != "" {
+			if _, err := ReadSecretFile(s.OAuthTokenFile); err != nil {
+				add("the KAFKA_SASL_OAUTH_TOKEN_FILE is not valid: %w", err)
+			}
+		}
+	default:
+		user, pass, err := s.Credentials()
+		if err != nil {
+			add("the Kafka SASL credentials are not valid: %w", err)
+		} else if user == "" || pass == "" {
+			add("a Kafka SASL user and password must be provided for the %s mechanism", s.Mechanism)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestSASL_Credentials(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "kafka-pass")
+	if err := os.WriteFile(file, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := SASL{Mechanism: SASLPlain, User: "relay", PasswordFile: file}
+	if errs := s.validate(); len(errs) > 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+
+	user, pass, err := s.Credentials()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if user != "relay" || pass != "secret" {
+		t.Errorf("expected the credentials 'relay' and 'secret', but got '%s' and '%s'", user, pass)
+	}
+
+	if diff := deep.Equal([]string{file}, s.CredentialFiles()); diff != nil {
+		t.Error(diff)
+	}
+
+	token, err := SASL{Mechanism: SASLOAuthBearer, OAuthTokenFile: file}.Token()
+	if err != nil || token != "secret" {
+		t.Errorf("expected the token to be read from its file, but got '%s' (%v)", token, err)
+	}
+
+	s.PasswordFile = file + "-missing"
+	if errs := s.validate(); len(errs) != 1 {
+		t.Errorf("expected 1 validation error for the missing password file, but got %v", errs)
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"inviqa/kafka-outbox-relay/log"
+)
+
+// ReadSecretFile reads a secret, such as a password, from the file at path,
+// ignoring any trailing new line.
+func ReadSecretFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the secret file %s: %w", path, err)
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// WatchSecretFile checks the file at path for changes every interval until ctx
+// is done, calling onChange whenever its contents change. Mounted secrets, e.g.
+// in Kubernetes, are usually updated by swapping a symlink, which file system
+// notifications do not reliably report, so the file is polled instead.
+func WatchSecretFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	last, _ := os.ReadFile(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b, err := os.ReadFile(path)
+			if err != nil {
+				log.Logger.WithError(err).Errorf("unable to read the secret file %s", path)
+				continue
+			}
+
+			if bytes.Equal(b, last) {
+				continue
+			}
+
+			log.Logger.Infof("the secret file %s has changed", path)
+			last = b
+			onChange()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// readPasswordFiles sets the password of each database that has a password
+// file from the file, so that the configuration is complete, and any file that
+// cannot be read is reported when the relay starts.
+func readPasswordFiles(dbs []Database) []error {
+	var errs []error
+	for i, db := range dbs {
+		if db.PasswordFile == "" {
+			continue
+		}
+
+		pass, err := ReadSecretFile(db.PasswordFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("the password for database %s is not valid: %w", db.Name, err))
+			continue
+		}
+		dbs[i].Password = pass
+	}
+
+	return errs
+}
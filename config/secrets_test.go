@@ -0,0 +1,111 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadSecretFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(file, []byte("passw0rd\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadSecretFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got != "passw0rd" {
+		t.Errorf("expected passw0rd, but got %q", got)
+	}
+
+	if _, err := ReadSecretFile(file + "-missing"); err == nil {
+		t.Error("expected an error for a missing file, but got nil")
+	}
+}
+
+func TestWatchSecretFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(file, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 10)
+	go WatchSecretFile(ctx, file, time.Millisecond, func() {
+		changed <- struct{}{}
+	})
+
+	select {
+	case <-changed:
+		t.Fatal("onChange was called before the file changed")
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	if err := os.WriteFile(file, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("onChange was not called after the file changed")
+	}
+}
+
+func TestNewConfig_WithPasswordFiles(t *testing.T) {
+	os.Args = nil
+	defer os.Clearenv()
+
+	dir := t.TempDir()
+	for name, pass := range map[string]string{"shared": "shared-pass\n", "orders": "orders-pass\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(pass), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := withoutEnvVars(getRequiredEnvVars(), "DB_NAME", "DB_PASS")
+	env["DB_PASS_FILE"] = filepath.Join(dir, "shared")
+	env["DB_1_NAME"] = "orders"
+	env["DB_1_PASS_FILE"] = filepath.Join(dir, "orders")
+	env["DB_2_NAME"] = "customers"
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+
+	got, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got.DBs[0].Password != "orders-pass" || got.DBs[0].PasswordFile != filepath.Join(dir, "orders") {
+		t.Errorf("the orders database password was not read from its own file: %+v", got.DBs[0])
+	}
+
+	if got.DBs[1].Password != "shared-pass" || got.DBs[1].PasswordFile != filepath.Join(dir, "shared") {
+		t.Errorf("the customers database password was not read from the shared file: %+v", got.DBs[1])
+	}
+}
+
+func TestNewConfig_WithPasswordAndPasswordFile(t *testing.T) {
+	os.Args = nil
+	defer os.Clearenv()
+
+	for k, v := range getEnvVars(map[string]string{"DB_PASS_FILE": "/run/secrets/db-pass"}) {
+		os.Setenv(k, v)
+	}
+
+	_, err := NewConfig()
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Errorf("expected 2 validation errors for the password and the missing password file, but got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// The formats that message payloads can be serialized to before they are
+// published. JSON payloads are published as they are.
+const (
+	SerializerJSON       = "json"
+	SerializerAvro       = "avro"
+	SerializerProtobuf   = "protobuf"
+	SerializerJSONSchema = "json-schema"
+
+	defaultSchemaRegistryCacheTTL = time.Minute * 5
+)
+
+var supportedSerializers = map[string]bool{
+	SerializerJSON:       true,
+	SerializerAvro:       true,
+	SerializerProtobuf:   true,
+	SerializerJSONSchema: true,
+}
+
+// SchemaRegistry holds the settings used to connect to a Confluent compatible
+// schema registry.
+type SchemaRegistry struct {
+	URL      string
+	User     string
+	Password string
+	// CacheTTL is how long each schema is cached for before the registry is
+	// checked for a new version.
+	CacheTTL time.Duration
+}
+
+func (a *args) schemaRegistry() SchemaRegistry {
+	return SchemaRegistry{
+		URL:      a.SchemaRegistryUrl,
+		User:     a.SchemaRegistryUser,
+		Password: a.SchemaRegistryPass,
+		CacheTTL: a.SchemaRegistryCacheTTL,
+	}
+}
+
+// serializers parses the KAFKA_SERIALIZERS, in the form <topic pattern>=<format>,
+// returning every entry that is not valid.
+func (a *args) serializers() (TopicPatterns, []error) {
+	var errs []error
+	add := func(format string, v ...any) {
+		errs = append(errs, fmt.Errorf(format, v...))
+	}
+
+	serializers, serializerErrs := parseTopicPatterns("KAFKA_SERIALIZERS", "format", "orders.*=avro", a.KafkaSerializers, func(format string) error {
+		if !supportedSerializers[format] {
+			return fmt.Errorf("the KAFKA_SERIALIZERS format provided (%s) must be one of json, avro, protobuf or json-schema", format)
+		}
+		return nil
+	})
+	errs = append(errs, serializerErrs...)
+
+	registryRequired := false
+	for _, s := range serializers {
+		registryRequired = registryRequired || s.Value != SerializerJSON
+	}
+
+	if a.SchemaRegistryUrl != "" {
+		if u, err := url.Parse(a.SchemaRegistryUrl); err != nil || u.Scheme == "" || u.Host == "" {
+			add("the SCHEMA_REGISTRY_URL provided (%s) is not a valid URL", a.SchemaRegistryUrl)
+		}
+	} else if registryRequired {
+		add("a SCHEMA_REGISTRY_URL must be provided to serialize payloads using a schema")
+	}
+
+	if a.SchemaRegistryCacheTTL < 0 {
+		add("the SCHEMA_REGISTRY_CACHE_TTL provided (%s) cannot be negative", a.SchemaRegistryCacheTTL)
+	}
+
+	return serializers, errs
+}
+
+func (r SchemaRegistry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"URL":      r.URL,
+		"User":     r.User,
+		"Pass":     "xxxxx",
+		"CacheTTL": r.CacheTTL.String(),
+	})
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// TLSFiles holds the paths to the PEM encoded files used to verify the
+// server's certificate against a private CA, and to authenticate with a client
+// certificate, i.e. mutual TLS. Any of them can be empty, in which case the
+// system CAs are used, and no client certificate is presented.
+type TLSFiles struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+func (a *args) kafkaTLSFiles() TLSFiles {
+	return TLSFiles{
+		CAFile:   a.KafkaTLSCAFile,
+		CertFile: a.KafkaTLSCertFile,
+		KeyFile:  a.KafkaTLSKeyFile,
+	}
+}
+
+// Configured returns whether any of the files have been provided.
+func (f TLSFiles) Configured() bool {
+	return f.CAFile != "" || f.CertFile != "" || f.KeyFile != ""
+}
+
+// TLSConfig creates a tls.Config from the files, which skips verification of
+// the server's certificate if skipVerify is true.
+func (f TLSFiles) TLSConfig(skipVerify bool) (*tls.Config, error) {
+	// #nosec G402
+	// InsecureSkipVerify depends on the TLS_SKIP_VERIFY_PEER configuration
+	cfg := &tls.Config{InsecureSkipVerify: skipVerify}
+
+	if f.CAFile != "" {
+		pem, err := os.ReadFile(f.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the CA file: %w", err)
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("the CA file %s does not contain any PEM encoded certificates", f.CAFile)
+		}
+	}
+
+	if f.CertFile != "" || f.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// postgresParams returns the DSN query parameters that configure the files
+// for the Postgres driver.
+func (f TLSFiles) postgresParams() string {
+	var params string
+	for _, p := range []struct{ name, file string }{
+		{"sslrootcert", f.CAFile},
+		{"sslcert", f.CertFile},
+		{"sslkey", f.KeyFile},
+	} {
+		if p.file != "" {
+			params += "&" + p.name + "=" + url.QueryEscape(p.file)
+		}
+	}
+
+	return params
+}
+
+// validate checks that the files can be loaded, using name to describe what
+// they are used to connect to.
+func (f TLSFiles) validate(name string, tlsEnable bool) []error {
+	if !f.Configured() {
+		return nil
+	}
+
+	if !tlsEnable {
+		return []error{fmt.Errorf("TLS must be enabled to use the TLS files provided for %s", name)}
+	}
+
+	if (f.CertFile == "") != (f.KeyFile == "") {
+		return []error{fmt.Errorf("both a client certificate and key must be provided for %s", name)}
+	}
+
+	if _, err := f.TLSConfig(false); err != nil {
+		return []error{fmt.Errorf("the TLS files provided for %s are not valid: %w", name, err)}
+	}
+
+	return nil
+}
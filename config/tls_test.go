@@ -0,0 +1,109 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTLSFiles_TLSConfig(t *testing.T) {
+	cert, key := writeCertificate(t)
+
+	got, err := TLSFiles{CAFile: cert, CertFile: cert, KeyFile: key}.TLSConfig(false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got.RootCAs == nil {
+		t.Error("expected the CA file to be used to verify the server's certificate")
+	}
+
+	if len(got.Certificates) != 1 {
+		t.Errorf("expected a client certificate, but got %d", len(got.Certificates))
+	}
+
+	if got.InsecureSkipVerify {
+		t.Error("expected TLS verification to be enabled")
+	}
+
+	if _, err := (TLSFiles{CAFile: key}).TLSConfig(false); err == nil {
+		t.Error("expected an error for a CA file without any certificates, but got nil")
+	}
+
+	if _, err := (TLSFiles{CertFile: cert, KeyFile: cert}).TLSConfig(false); err == nil {
+		t.Error("expected an error for an invalid client key, but got nil")
+	}
+}
+
+func TestTLSFiles_validate(t *testing.T) {
+	cert, key := writeCertificate(t)
+
+	tests := []struct {
+		name      string
+		files     TLSFiles
+		tlsEnable bool
+		wantErr   bool
+	}{
+		{name: "no files", tlsEnable: false},
+		{name: "valid files", files: TLSFiles{CAFile: cert, CertFile: cert, KeyFile: key}, tlsEnable: true},
+		{name: "TLS disabled", files: TLSFiles{CAFile: cert}, tlsEnable: false, wantErr: true},
+		{name: "certificate without a key", files: TLSFiles{CertFile: cert}, tlsEnable: true, wantErr: true},
+		{name: "missing CA file", files: TLSFiles{CAFile: cert + "-missing"}, tlsEnable: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.files.validate("Kafka", tt.tlsEnable); (len(errs) > 0) != tt.wantErr {
+				t.Errorf("validate() returned %v, but wanted an error: %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeCertificate writes a self-signed certificate and its key to PEM files,
+// returning their paths.
+func writeCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka-outbox-relay"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TopicRewrite holds the rules used to rewrite the logical topic of each
+// message into the topic that it is published to, see kafka.TopicRewriter.
+type TopicRewrite struct {
+	Prefix   string
+	Suffix   string
+	Rewrites []TopicRewriteRule
+	// MappingFile is the path to a YAML file mapping logical topics to the
+	// topics they are published to, which is loaded into Mapping.
+	MappingFile string
+	Mapping     map[string]string
+	Blocked     []string
+}
+
+// TopicRewriteRule replaces every match of the Pattern regular expression in a
+// topic with Replacement.
+type TopicRewriteRule struct {
+	Pattern     string
+	Replacement string
+}
+
+// topicRewrite parses the topic rewrite settings, loading the mapping file if
+// one was provided, and returns every setting that is not valid.
+func (a *args) topicRewrite() (TopicRewrite, []error) {
+	var errs []error
+	add := func(format string, v ...any) {
+		errs = append(errs, fmt.Errorf(format, v...))
+	}
+
+	tr := TopicRewrite{
+		Prefix:      a.KafkaTopicPrefix,
+		Suffix:      a.KafkaTopicSuffix,
+		MappingFile: a.KafkaTopicMappingFile,
+		Blocked:     a.KafkaTopicBlock,
+	}
+
+	for _, r := range a.KafkaTopicRewrites {
+		pattern, replacement, ok := strings.Cut(r, "=")
+		if !ok || pattern == "" {
+			add("the KAFKA_TOPIC_REWRITES entry provided (%s) must be in the form <regular expression>=<replacement>", r)
+			continue
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			add("the KAFKA_TOPIC_REWRITES pattern provided (%s) is not a valid regular expression: %w", pattern, err)
+			continue
+		}
+		tr.Rewrites = append(tr.Rewrites, TopicRewriteRule{Pattern: pattern, Replacement: replacement})
+	}
+
+	for _, pattern := range tr.Blocked {
+		if _, err := path.Match(pattern, ""); err != nil {
+			add("the KAFKA_TOPIC_BLOCK pattern provided (%s) is not valid: %w", pattern, err)
+		}
+	}
+
+	if tr.MappingFile != "" {
+		var err error
+		if tr.Mapping, err = readTopicMapping(tr.MappingFile); err != nil {
+			add("the KAFKA_TOPIC_MAPPING_FILE provided (%s) is not valid: %w", tr.MappingFile, err)
+		}
+	}
+
+	return tr, errs
+}
+
+// readTopicMapping reads a YAML file mapping each logical topic to the topic
+// that it is published to, e.g.
+//
+//	productUpdate: catalog.product-updated
+func readTopicMapping(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := map[string]string{}
+	if err := yaml.Unmarshal(b, &mapping); err != nil {
+		return nil, fmt.Errorf("unable to parse the topic mapping: %w", err)
+	}
+
+	for from, to := range mapping {
+		if to == "" {
+			return nil, fmt.Errorf("the topic %s is mapped to an empty topic", from)
+		}
+	}
+
+	return mapping, nil
+}
+
+// Enabled returns whether any topics are rewritten or blocked.
+func (t TopicRewrite) Enabled() bool {
+	return t.Prefix != "" || t.Suffix != "" || len(t.Rewrites) > 0 || len(t.Mapping) > 0 || len(t.Blocked) > 0
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestNewConfig_WithTopicRewrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "topics.yaml")
+	if err := os.WriteFile(file, []byte("productUpdate: catalog.product-updated\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = nil
+	defer os.Clearenv()
+	for k, v := range getEnvVars(map[string]string{
+		"KAFKA_TOPIC_PREFIX":       "staging.",
+		"KAFKA_TOPIC_REWRITES":     `^orders\.v(\d+)$=orders-v${1}`,
+		"KAFKA_TOPIC_MAPPING_FILE": file,
+		"KAFKA_TOPIC_BLOCK":        "internal.*,audit",
+	}) {
+		os.Setenv(k, v)
+	}
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := TopicRewrite{
+		Prefix:      "staging.",
+		Rewrites:    []TopicRewriteRule{{Pattern: `^orders\.v(\d+)$`, Replacement: "orders-v${1}"}},
+		MappingFile: file,
+		Mapping:     map[string]string{"productUpdate": "catalog.product-updated"},
+		Blocked:     []string{"internal.*", "audit"},
+	}
+	if diff := deep.Equal(exp, cfg.KafkaTopics); diff != nil {
+		t.Error(diff)
+	}
+
+	if !cfg.KafkaTopics.Enabled() {
+		t.Error("expected topic rewriting to be enabled")
+	}
+}
+
+func TestNewConfig_WithInvalidTopicRewrite(t *testing.T) {
+	os.Args = nil
+	defer os.Clearenv()
+	for k, v := range getEnvVars(map[string]string{
+		"KAFKA_TOPIC_REWRITES":     "orders(=orders,no-replacement",
+		"KAFKA_TOPIC_MAPPING_FILE": filepath.Join(t.TempDir(), "missing.yaml"),
+		"KAFKA_TOPIC_BLOCK":        "[",
+	}) {
+		os.Setenv(k, v)
+	}
+
+	_, err := NewConfig()
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validation errors, but got %v", err)
+	}
+
+	if len(errs) != 4 {
+		t.Errorf("expected 4 validation errors, but got %d: %s", len(errs), errs)
+	}
+}
+
+func TestReadTopicMapping(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "topics.yaml")
+	if err := os.WriteFile(file, []byte("productUpdate: \"\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readTopicMapping(file); err == nil {
+		t.Error("expected an error for the topic mapped to an empty topic, but got nil")
+	}
+
+	if err := os.WriteFile(file, []byte("- productUpdate\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readTopicMapping(file); err == nil {
+		t.Error("expected an error for the mapping that is not a map, but got nil")
+	}
+}
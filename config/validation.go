@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ValidationErrors is returned by NewConfig when the configuration is not
+// valid, and lists every problem that was found, rather than just the first.
+type ValidationErrors []error
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, err := range v {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("the configuration is not valid: %s", strings.Join(msgs, "; "))
+}
+
+func (a *args) validate() []error {
+	var errs []error
+	add := func(format string, v ...any) {
+		errs = append(errs, fmt.Errorf(format, v...))
+	}
+
+	if a.StaleBatchTimeout <= 0 {
+		add("the STALE_BATCH_TIMEOUT provided (%s) must be greater than zero", a.StaleBatchTimeout)
+	}
+
+	if a.KafkaRetryBaseDelay < 0 || a.KafkaRetryMaxDelay < a.KafkaRetryBaseDelay {
+		add("the KAFKA_RETRY_MAX_DELAY provided (%s) must not be less than KAFKA_RETRY_BASE_DELAY (%s), which cannot be negative", a.KafkaRetryMaxDelay, a.KafkaRetryBaseDelay)
+	}
+
+	if a.SizeMetricsInterval < 0 {
+		add("the SIZE_METRICS_INTERVAL provided (%s) cannot be negative", a.SizeMetricsInterval)
+	}
+
+	if a.SecretsPollInterval <= 0 {
+		add("the SECRETS_POLL_INTERVAL provided (%s) must be greater than zero", a.SecretsPollInterval)
+	}
+
+	if a.DBPass != "" && a.DBPassFile != "" {
+		add("DB_PASS and DB_PASS_FILE cannot both be provided")
+	}
+
+	if a.WriteConcurrency < 1 {
+		add("the WRITE_CONCURRENCY provided (%d) must be at least 1", a.WriteConcurrency)
+	}
+
+	if a.BatchSize < 1 {
+		add("the BATCH_SIZE provided (%d) must be at least 1", a.BatchSize)
+	}
+
+	if a.PollFrequencyMs < 1 {
+		add("the POLL_FREQUENCY_MS provided (%d) must be at least 1", a.PollFrequencyMs)
+	}
+
+	if a.KafkaPublishAttempts < 1 {
+		add("the KAFKA_PUBLISH_ATTEMPTS provided (%d) must be at least 1", a.KafkaPublishAttempts)
+	}
+
+	if a.KafkaRetryAttempts < 1 {
+		add("the KAFKA_RETRY_ATTEMPTS provided (%d) must be at least 1", a.KafkaRetryAttempts)
+	}
+
+	jobs := 0
+	for _, run := range []bool{a.RunCleanup, a.RunOptimize, a.RunRequeueErrored} {
+		if run {
+			jobs++
+		}
+	}
+	if jobs > 1 {
+		add("only one of RUN_CLEANUP, RUN_OPTIMIZE and RUN_REQUEUE_ERRORED can be enabled")
+	}
+
+	// the jobs never connect to Kafka, and neither does the relay when polling
+	// is disabled
+	if jobs == 0 && !a.PollingDisabled && len(a.KafkaHost) == 0 {
+		add("a KAFKA_HOST must be provided, unless POLLING_DISABLED is enabled")
+	}
+
+	errs = append(errs, a.sasl().validate()...)
+	errs = append(errs, a.kafkaTLSFiles().validate("Kafka", a.TLSEnable)...)
+
+	if a.RequeueMinId > 0 && a.RequeueMaxId > 0 && a.RequeueMinId > a.RequeueMaxId {
+		add("the REQUEUE_MIN_ID provided (%d) must not be greater than REQUEUE_MAX_ID (%d)", a.RequeueMinId, a.RequeueMaxId)
+	}
+
+	if a.SidecarProxyUrl != "" {
+		if u, err := url.Parse(a.SidecarProxyUrl); err != nil || u.Scheme == "" || u.Host == "" {
+			add("the SIDECAR_PROXY_URL provided (%s) is not a valid URL", a.SidecarProxyUrl)
+		}
+	}
+
+	return errs
+}
+
+func validateDatabases(dbs []Database) []error {
+	if len(dbs) == 0 {
+		return []error{fmt.Errorf("no databases are configured, either DB_NAME or the indexed DB_<n>_NAME env vars must be provided")}
+	}
+
+	var errs []error
+	names := map[string]bool{}
+	for _, db := range dbs {
+		errs = append(errs, db.validate()...)
+
+		// the database name identifies each database in logs, metrics and
+		// Kafka transactional IDs, so it must be unique
+		if names[db.Name] {
+			errs = append(errs, fmt.Errorf("the database name %s is configured more than once", db.Name))
+		}
+		names[db.Name] = true
+	}
+
+	return errs
+}
+
+func (d Database) validate() []error {
+	var errs []error
+	add := func(format string, v ...any) {
+		errs = append(errs, fmt.Errorf(format, v...))
+	}
+
+	if d.Name == "" {
+		add("a database name must be provided for every database")
+	}
+	if !supportedDbTypes[d.Driver] {
+		add("the DB_DRIVER provided (%s) for database %s is not supported", d.Driver, d.Name)
+	}
+	if d.Host == "" {
+		add("a database host must be provided for database %s", d.Name)
+	}
+	if d.Port == 0 {
+		add("a database port must be provided for database %s", d.Name)
+	}
+	if d.User == "" {
+		add("a database user must be provided for database %s", d.Name)
+	}
+	if !outboxTableName.MatchString(d.OutboxTable) {
+		add("the outbox table provided (%s) for database %s must be a table name, optionally qualified by its schema, e.g. events.kafka_outbox", d.OutboxTable, d.Name)
+	} else if d.Driver.MySQL() && strings.Contains(d.OutboxTable, ".") {
+		// the MySQL migrations are tracked in the database that the relay
+		// connects to, so the outbox table must be in that database too
+		add("the outbox table provided (%s) for database %s cannot be qualified by its schema when using MySQL, set the database name instead", d.OutboxTable, d.Name)
+	}
+	if d.BatchSize < 0 || d.WriteConcurrency < 0 {
+		add("the batch size and write concurrency for database %s cannot be negative", d.Name)
+	}
+	errs = append(errs, d.TLSFiles.validate("database "+d.Name, d.TLSEnable)...)
+
+	return errs
+}
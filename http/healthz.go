@@ -1,16 +1,19 @@
 package http
 
 import (
+	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"time"
 
+	"inviqa/kafka-outbox-relay/config"
 	"inviqa/kafka-outbox-relay/log"
 )
 
 type healthzHandler struct {
-	checkAddr []string
-	dbs       []Pinger
+	clusters map[string][]string
+	dbs      []Pinger
 }
 
 type Pinger interface {
@@ -18,24 +21,40 @@ type Pinger interface {
 }
 
 func NewHealthzHandler(checkAddr []string, dbs []Pinger) http.Handler {
+	return NewHealthzHandlerForClusters(map[string][]string{config.DefaultKafkaCluster: checkAddr}, dbs)
+}
+
+// NewHealthzHandlerForClusters creates a healthz handler that checks the
+// connectivity to the hosts of each Kafka cluster, keyed by the cluster name.
+// The readiness check lists any cluster that cannot be reached in the body of
+// its response.
+func NewHealthzHandlerForClusters(clusters map[string][]string, dbs []Pinger) http.Handler {
 	return &healthzHandler{
-		checkAddr: checkAddr,
-		dbs:       dbs,
+		clusters: clusters,
+		dbs:      dbs,
 	}
 }
 
 func (h healthzHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var healthy bool
+	var unhealthy []string
 	if req.URL.Query().Get("readiness") == "1" {
-		healthy = h.checkServices() && h.checkDatabase()
-	} else {
-		healthy = h.checkDatabase()
+		for _, name := range h.checkServices() {
+			unhealthy = append(unhealthy, fmt.Sprintf("the %s Kafka cluster is not available", name))
+		}
+	}
+	if !h.checkDatabase() {
+		unhealthy = append(unhealthy, "the database is not available")
 	}
 
-	if healthy {
+	if len(unhealthy) == 0 {
 		w.WriteHeader(http.StatusOK)
-	} else {
-		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	for _, reason := range unhealthy {
+		_, _ = fmt.Fprintln(w, reason)
 	}
 }
 
@@ -49,17 +68,31 @@ func (h healthzHandler) checkDatabase() bool {
 	return true
 }
 
-func (h healthzHandler) checkServices() bool {
-	healthy := true
-	for _, host := range h.checkAddr {
-		log.Logger.Debugf("checking connectivity to %s", host)
-		conn, err := net.DialTimeout("tcp", host, 1*time.Second)
-		if err != nil {
-			healthy = false
-			log.Logger.Debugf("unable to connect to %s", host)
-		} else {
-			_ = conn.Close()
+// checkServices returns the names of the Kafka clusters with any host that
+// cannot be reached.
+func (h healthzHandler) checkServices() []string {
+	names := make([]string, 0, len(h.clusters))
+	for name := range h.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var unhealthy []string
+	for _, name := range names {
+		healthy := true
+		for _, host := range h.clusters[name] {
+			log.Logger.Debugf("checking connectivity to %s in the %s Kafka cluster", host, name)
+			conn, err := net.DialTimeout("tcp", host, 1*time.Second)
+			if err != nil {
+				healthy = false
+				log.Logger.Debugf("unable to connect to %s in the %s Kafka cluster", host, name)
+			} else {
+				_ = conn.Close()
+			}
+		}
+		if !healthy {
+			unhealthy = append(unhealthy, name)
 		}
 	}
-	return healthy
+	return unhealthy
 }
@@ -6,6 +6,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"inviqa/kafka-outbox-relay/config"
 )
 
 func TestNewHealthzHandler(t *testing.T) {
@@ -40,6 +42,29 @@ func TestHealthzHandler_ServeHTTP_ReadinessWhenServiceUnhealthy(t *testing.T) {
 	}
 }
 
+func TestHealthzHandler_ServeHTTP_ReadinessWhenClusterUnhealthy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	recorder := httptest.NewRecorder()
+	handler := NewHealthzHandlerForClusters(map[string][]string{
+		config.DefaultKafkaCluster: {strings.Replace(srv.URL, "http://", "", 1)},
+		"compliance":               {"foo:9090"},
+	}, mockPingers())
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz?readiness=1", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 response code, but got %d", recorder.Code)
+	}
+
+	exp := "the compliance Kafka cluster is not available\n"
+	if body := recorder.Body.String(); body != exp {
+		t.Errorf("expected the unhealthy cluster to be reported as '%s', but got '%s'", exp, body)
+	}
+}
+
 func TestHealthzHandler_ServeHTTP_ReadinessWhenDbUnavailable(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -12,19 +12,6 @@ import (
 	"inviqa/kafka-outbox-relay/outbox"
 )
 
-func ensureOutboxTableExists() {
-	var q string
-	if dbCfg.Driver.MySQL() {
-		q = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE kafka_outbox;", dbCfg.OutboxTable)
-	} else {
-		q = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE kafka_outbox INCLUDING ALL);", dbCfg.OutboxTable)
-	}
-	_, err := db.Exec(q)
-	if err != nil {
-		panic(fmt.Sprintf("an error occurred creating the outbox table for integration tests: %s", err))
-	}
-}
-
 func purgeOutboxTable() {
 	_, err := db.Exec(fmt.Sprintf("TRUNCATE TABLE %s;", dbCfg.OutboxTable))
 	if err != nil {
@@ -47,8 +34,8 @@ func insertOutboxMessages(msgs []*outbox.Message) {
 		var err error
 		var id int64
 		if dbCfg.Driver.MySQL() {
-			q = fmt.Sprintf("INSERT INTO `%s` SET batch_id = ?, topic = ?, push_started_at = ?, push_completed_at = ?, payload_json = ?, payload_headers = ?, push_attempts = ?, `key` = ?, partition_key = ?;", dbCfg.OutboxTable)
-			res, err := tx.Exec(q, msg.BatchId, msg.Topic, msg.PushStartedAt, msg.PushCompletedAt, msg.PayloadJson, msg.PayloadHeaders, msg.PushAttempts, msg.Key, msg.PartitionKey)
+			q = fmt.Sprintf("INSERT INTO `%s` SET batch_id = ?, topic = ?, push_started_at = ?, push_completed_at = ?, payload_json = ?, payload_headers = ?, push_attempts = ?, `key` = ?, partition_key = ?, `partition` = ?;", dbCfg.OutboxTable)
+			res, err := tx.Exec(q, msg.BatchId, msg.Topic, msg.PushStartedAt, msg.PushCompletedAt, msg.PayloadJson, msg.PayloadHeaders, msg.PushAttempts, msg.Key, msg.PartitionKey, msg.Partition)
 			if err != nil {
 				panic(fmt.Sprintf("failed to insert outbox message in MySQL: %s", err))
 			}
@@ -58,8 +45,8 @@ func insertOutboxMessages(msgs []*outbox.Message) {
 				panic(fmt.Sprintf("failed to determine last insert ID for the inserted outbox message: %s", err))
 			}
 		} else {
-			q = fmt.Sprintf("INSERT INTO %s(batch_id, topic, push_started_at, push_completed_at, payload_json, payload_headers, push_attempts, key, partition_key) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;", dbCfg.OutboxTable)
-			err = tx.QueryRow(q, msg.BatchId, msg.Topic, msg.PushStartedAt, msg.PushCompletedAt, msg.PayloadJson, msg.PayloadHeaders, msg.PushAttempts, msg.Key, msg.PartitionKey).Scan(&id)
+			q = fmt.Sprintf("INSERT INTO %s(batch_id, topic, push_started_at, push_completed_at, payload_json, payload_headers, push_attempts, key, partition_key, partition) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id;", dbCfg.OutboxTable)
+			err = tx.QueryRow(q, msg.BatchId, msg.Topic, msg.PushStartedAt, msg.PushCompletedAt, msg.PayloadJson, msg.PayloadHeaders, msg.PushAttempts, msg.Key, msg.PartitionKey, msg.Partition).Scan(&id)
 			if err != nil {
 				panic(fmt.Sprintf("failed to insert outbox message in Postgres: %s", err))
 			}
@@ -111,3 +98,15 @@ func outboxMessageExists(id uint) bool {
 
 	return count > 0
 }
+
+func markOutboxMessageErrored(id uint, reason string) {
+	q := fmt.Sprintf("UPDATE %s SET errored = 1, error_reason = ?, push_attempts = 3 WHERE id = ?", dbCfg.OutboxTable)
+	if dbCfg.Driver.Postgres() {
+		q = strings.Replace(q, "?", "$1", 1)
+		q = strings.Replace(q, "?", "$2", 1)
+	}
+
+	if _, err := db.Exec(q, reason, id); err != nil {
+		panic(fmt.Sprintf("an error occurred marking the outbox message as errored: %s", err))
+	}
+}
@@ -57,7 +57,6 @@ func init() {
 	db = dbs[0].Connection()
 	repo = outbox.NewRepository(dbs[0], cfg)
 
-	ensureOutboxTableExists()
 	purgeOutboxTable()
 
 	go pollForMessages(nrApp)
@@ -147,6 +146,8 @@ func setupConfig() *config.Config {
 		PollFrequencyMs:      1000,
 		SidecarProxyUrl:      server.URL,
 		KafkaPublishAttempts: 3,
+		KafkaRetryAttempts:   20,
+		StaleBatchTimeout:    time.Minute * 10,
 		BatchSize:            250,
 		KafkaHost:            []string{"localhost:9092"},
 	}
@@ -187,7 +188,8 @@ func setupConfig() *config.Config {
 func pollForMessages(nrApp *nr.Application) {
 	batchCh := make(chan *outbox.Batch, 10)
 
-	go poller.New(repo, batchCh, nrApp).Poll(context.Background(), time.Millisecond*100)
+	go poller.New(repo, batchCh, dbCfg.Name, nrApp).Poll(context.Background(), time.Millisecond*100)
+	go poller.ReleaseStaleBatches(context.Background(), repo, dbCfg.Name, time.Millisecond*100, nrApp)
 
 	processor.NewBatchProcessor(repo, pub, nrApp).ListenAndProcess(context.Background(), batchCh)
 }
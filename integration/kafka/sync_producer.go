@@ -4,6 +4,7 @@
 package kafka
 
 import (
+	"errors"
 	"sync"
 
 	"inviqa/kafka-outbox-relay/kafka"
@@ -51,9 +52,66 @@ func (sp *SyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int3
 }
 
 func (sp *SyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	sp.RLock()
+	defer sp.RUnlock()
+
+	var errs sarama.ProducerErrors
+	var toSend []*sarama.ProducerMessage
+	for _, msg := range msgs {
+		b, err := msg.Value.Encode()
+		if err != nil {
+			panic(err)
+		}
+		if err, ok := sp.msgsToError[string(b)]; ok {
+			errs = append(errs, &sarama.ProducerError{Msg: msg, Err: err})
+			continue
+		}
+		toSend = append(toSend, msg)
+	}
+
+	if err := sp.realSyncProducer.SendMessages(toSend); err != nil {
+		var prodErrs sarama.ProducerErrors
+		if !errors.As(err, &prodErrs) {
+			return err
+		}
+		errs = append(errs, prodErrs...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
 	return nil
 }
 
 func (sp *SyncProducer) Close() error {
 	return nil
 }
+
+func (sp *SyncProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return sp.realSyncProducer.TxnStatus()
+}
+
+func (sp *SyncProducer) IsTransactional() bool {
+	return sp.realSyncProducer.IsTransactional()
+}
+
+func (sp *SyncProducer) BeginTxn() error {
+	return sp.realSyncProducer.BeginTxn()
+}
+
+func (sp *SyncProducer) CommitTxn() error {
+	return sp.realSyncProducer.CommitTxn()
+}
+
+func (sp *SyncProducer) AbortTxn() error {
+	return sp.realSyncProducer.AbortTxn()
+}
+
+func (sp *SyncProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupId string) error {
+	return sp.realSyncProducer.AddOffsetsToTxn(offsets, groupId)
+}
+
+func (sp *SyncProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupId string, metadata *string) error {
+	return sp.realSyncProducer.AddMessageToTxn(msg, groupId, metadata)
+}
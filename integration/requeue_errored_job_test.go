@@ -0,0 +1,70 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"inviqa/kafka-outbox-relay/job"
+	"inviqa/kafka-outbox-relay/outbox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRequeueErroredJobRequeuesMatchingMessages(t *testing.T) {
+	purgeOutboxTable()
+
+	Convey("Given there are errored messages in the outbox", t, func() {
+		msg1 := &outbox.Message{
+			PayloadJson: []byte(`{"foo": "bar"}`),
+			Topic:       "testProductUpdate",
+		}
+		msg2 := &outbox.Message{
+			PayloadJson: []byte(`{"foo": "bar"}`),
+			Topic:       "testProductUpdate",
+		}
+		msg3 := &outbox.Message{
+			PayloadJson: []byte(`{"foo": "bar"}`),
+			Topic:       "testStockUpdate",
+		}
+		insertOutboxMessages([]*outbox.Message{msg1, msg2, msg3})
+		markOutboxMessageErrored(msg1.Id, "kafka server: Message was too large")
+		markOutboxMessageErrored(msg2.Id, "kafka server: Request timed out")
+		markOutboxMessageErrored(msg3.Id, "kafka server: Message was too large")
+
+		Convey("When we execute a dry run of the requeue errored job", func() {
+			requeueCfg := *cfg
+			requeueCfg.Requeue.DryRun = true
+			requeueCfg.Requeue.Topic = "testProductUpdate"
+			code := job.RunRequeueErrored(context.Background(), nil, dbs, &requeueCfg)
+
+			Convey("Then no messages should have been requeued", func() {
+				So(code, ShouldEqual, 0)
+				So(getOutboxMessage(msg1.Id).Errored, ShouldBeTrue)
+				So(getOutboxMessage(msg2.Id).Errored, ShouldBeTrue)
+				So(getOutboxMessage(msg3.Id).Errored, ShouldBeTrue)
+			})
+		})
+
+		Convey("When we execute the requeue errored job filtered by topic and error reason", func() {
+			requeueCfg := *cfg
+			requeueCfg.Requeue.Topic = "testProductUpdate"
+			requeueCfg.Requeue.ErrorReasonPattern = "%too large%"
+			code := job.RunRequeueErrored(context.Background(), nil, dbs, &requeueCfg)
+
+			Convey("Then only the matching message should have been requeued", func() {
+				So(code, ShouldEqual, 0)
+
+				requeued := getOutboxMessage(msg1.Id)
+				So(requeued.Errored, ShouldBeFalse)
+				So(requeued.PushAttempts, ShouldEqual, 0)
+				So(requeued.BatchId, ShouldBeNil)
+
+				So(getOutboxMessage(msg2.Id).Errored, ShouldBeTrue)
+				So(getOutboxMessage(msg3.Id).Errored, ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/newrelic/go-agent/v3/newrelic"
 
+	"inviqa/kafka-outbox-relay/config"
 	"inviqa/kafka-outbox-relay/log"
 )
 
@@ -19,7 +20,7 @@ type mysqlOptimizeTable struct {
 func (o *mysqlOptimizeTable) Execute(ctx context.Context) error {
 	defer o.newRelicSegment(ctx, "OPTIMIZE TABLE").End()
 
-	_, err := o.Db.Exec(fmt.Sprintf("OPTIMIZE TABLE %s;", o.TableName))
+	_, err := o.Db.Exec(fmt.Sprintf("OPTIMIZE TABLE %s;", config.MySQL.QuoteIdentifier(o.TableName)))
 
 	if err == nil {
 		log.Logger.Info("optimized MySQL outbox table successfully")
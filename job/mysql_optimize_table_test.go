@@ -13,7 +13,7 @@ import (
 func TestMysqlOptimizeTable_Execute(t *testing.T) {
 	ctx := context.Background()
 	db, mock, _ := sqlmock.New()
-	mock.ExpectExec("OPTIMIZE TABLE outbox;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("OPTIMIZE TABLE `outbox`;").WillReturnResult(sqlmock.NewResult(0, 0))
 
 	j := &mysqlOptimizeTable{
 		Db:             db,
@@ -34,7 +34,7 @@ func TestMysqlOptimizeTable_Execute(t *testing.T) {
 func TestMysqlOptimizeTable_ExecuteWithError(t *testing.T) {
 	ctx := context.Background()
 	db, mock, _ := sqlmock.New()
-	mock.ExpectExec("OPTIMIZE TABLE outbox;").WillReturnError(errors.New("oops"))
+	mock.ExpectExec("OPTIMIZE TABLE `outbox`;").WillReturnError(errors.New("oops"))
 
 	j := &mysqlOptimizeTable{
 		Db:             db,
@@ -55,7 +55,7 @@ func TestMysqlOptimizeTable_ExecuteWithError(t *testing.T) {
 func TestMysqlOptimizeTable_ExecuteWithSidecarProxyQuit(t *testing.T) {
 	ctx := context.Background()
 	db, mock, _ := sqlmock.New()
-	mock.ExpectExec("OPTIMIZE TABLE outbox;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("OPTIMIZE TABLE `outbox`;").WillReturnResult(sqlmock.NewResult(0, 0))
 	cl := test.NewMockHttpClient()
 	j := &mysqlOptimizeTable{
 		Db:             db,
@@ -81,7 +81,7 @@ func TestMysqlOptimizeTable_ExecuteWithSidecarProxyQuit(t *testing.T) {
 func TestMysqlOptimizeTable_ExecuteWithSidecarProxyQuitClientError(t *testing.T) {
 	ctx := context.Background()
 	db, mock, _ := sqlmock.New()
-	mock.ExpectExec("OPTIMIZE TABLE outbox;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("OPTIMIZE TABLE `outbox`;").WillReturnResult(sqlmock.NewResult(0, 0))
 	cl := test.NewMockHttpClient()
 	cl.ReturnErrors()
 	j := &mysqlOptimizeTable{
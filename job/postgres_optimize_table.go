@@ -7,6 +7,7 @@ import (
 
 	"github.com/newrelic/go-agent/v3/newrelic"
 
+	"inviqa/kafka-outbox-relay/config"
 	"inviqa/kafka-outbox-relay/log"
 )
 
@@ -19,7 +20,7 @@ type postgresOptimizeTable struct {
 func (o *postgresOptimizeTable) Execute(ctx context.Context) error {
 	defer o.newRelicSegment(ctx, "VACUUM").End()
 
-	_, err := o.Db.Exec(fmt.Sprintf("VACUUM %s;", o.TableName))
+	_, err := o.Db.Exec(fmt.Sprintf("VACUUM %s;", config.Postgres.QuoteIdentifier(o.TableName)))
 
 	if err == nil {
 		log.Logger.Info("optimized Postgres outbox table successfully")
@@ -13,7 +13,7 @@ import (
 func TestPostgresOptimizeTable_Execute(t *testing.T) {
 	ctx := context.Background()
 	db, mock, _ := sqlmock.New()
-	mock.ExpectExec("VACUUM outbox;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`VACUUM "outbox";`).WillReturnResult(sqlmock.NewResult(0, 0))
 
 	j := &postgresOptimizeTable{
 		Db:             db,
@@ -34,7 +34,7 @@ func TestPostgresOptimizeTable_Execute(t *testing.T) {
 func TestPostgresOptimizeTable_ExecuteWithError(t *testing.T) {
 	ctx := context.Background()
 	db, mock, _ := sqlmock.New()
-	mock.ExpectExec("VACUUM outbox;").WillReturnError(errors.New("oops"))
+	mock.ExpectExec(`VACUUM "outbox";`).WillReturnError(errors.New("oops"))
 
 	j := &postgresOptimizeTable{
 		Db:             db,
@@ -55,7 +55,7 @@ func TestPostgresOptimizeTable_ExecuteWithError(t *testing.T) {
 func TestPostgresOptimizeTable_ExecuteWithSidecarProxyQuit(t *testing.T) {
 	ctx := context.Background()
 	db, mock, _ := sqlmock.New()
-	mock.ExpectExec("VACUUM outbox;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`VACUUM "outbox";`).WillReturnResult(sqlmock.NewResult(0, 0))
 	cl := test.NewMockHttpClient()
 	j := &postgresOptimizeTable{
 		Db:             db,
@@ -81,7 +81,7 @@ func TestPostgresOptimizeTable_ExecuteWithSidecarProxyQuit(t *testing.T) {
 func TestPostgresOptimizeTable_ExecuteWithSidecarProxyQuitClientError(t *testing.T) {
 	ctx := context.Background()
 	db, mock, _ := sqlmock.New()
-	mock.ExpectExec("VACUUM outbox;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`VACUUM "outbox";`).WillReturnResult(sqlmock.NewResult(0, 0))
 	cl := test.NewMockHttpClient()
 	cl.ReturnErrors()
 	j := &postgresOptimizeTable{
@@ -0,0 +1,106 @@
+package job
+
+import (
+	"context"
+	"net/http"
+
+	nr "github.com/newrelic/go-agent/v3/newrelic"
+
+	"inviqa/kafka-outbox-relay/config"
+	"inviqa/kafka-outbox-relay/log"
+	"inviqa/kafka-outbox-relay/newrelic"
+	"inviqa/kafka-outbox-relay/outbox"
+	"inviqa/kafka-outbox-relay/outbox/data"
+	s "inviqa/kafka-outbox-relay/outbox/data/sql"
+)
+
+type erroredRequeuer interface {
+	CountErrored(ctx context.Context, f s.ErroredFilter) (int64, error)
+	RequeueErrored(ctx context.Context, f s.ErroredFilter) (int64, error)
+}
+
+type requeueErrored struct {
+	SidecarQuitter
+	requeuerFactory func() erroredRequeuer
+	filter          s.ErroredFilter
+	dryRun          bool
+	dbName          string
+}
+
+func RunRequeueErrored(parent context.Context, nrApp *nr.Application, dbs data.DBs, cfg *config.Config) int {
+	ctx, txn := newrelic.ContextWithTxn(parent, "run requeue errored", nrApp)
+	defer txn.End()
+
+	var exitCode int
+	dbs.Each(func(db data.DB) {
+		exitCode += doRequeueErrored(ctx, db, cfg)
+	})
+	return normalizeExitCode(exitCode)
+}
+
+func doRequeueErrored(ctx context.Context, db data.DB, cfg *config.Config) int {
+	txn := nr.FromContext(ctx)
+	defer txn.StartSegment("doRequeueErrored() " + db.Config().Driver.String()).End()
+
+	j := newRequeueErroredWithDefaults(db, cfg)
+
+	if cfg.SidecarProxyUrl != "" {
+		j.EnableSideCarProxyQuit(cfg.SidecarProxyUrl)
+	}
+
+	if err := j.Execute(ctx); err != nil {
+		txn.NoticeError(err)
+		return 1
+	}
+
+	return 0
+}
+
+func newRequeueErroredWithDefaults(db data.DB, cfg *config.Config) *requeueErrored {
+	return &requeueErrored{
+		requeuerFactory: func() erroredRequeuer {
+			return outbox.NewRepository(db, cfg)
+		},
+		SidecarQuitter: SidecarQuitter{
+			Client: http.DefaultClient,
+		},
+		filter: s.ErroredFilter{
+			Topic:              cfg.Requeue.Topic,
+			MinId:              cfg.Requeue.MinId,
+			MaxId:              cfg.Requeue.MaxId,
+			CreatedAfter:       cfg.Requeue.CreatedAfter,
+			CreatedBefore:      cfg.Requeue.CreatedBefore,
+			ErrorReasonPattern: cfg.Requeue.ErrorReasonPattern,
+		},
+		dryRun: cfg.Requeue.DryRun,
+		dbName: db.Config().Name,
+	}
+}
+
+func (r *requeueErrored) Execute(ctx context.Context) error {
+	logger := log.Logger.WithField("database", r.dbName)
+	repo := r.requeuerFactory()
+
+	if r.dryRun {
+		count, err := repo.CountErrored(ctx, r.filter)
+		if err != nil {
+			logger.WithError(err).Error("an error occurred whilst counting errored outbox records")
+			return err
+		}
+		logger.Infof("dry run: %d errored outbox records would be requeued", count)
+	} else {
+		rows, err := repo.RequeueErrored(ctx, r.filter)
+		if err != nil {
+			logger.WithError(err).Error("an error occurred whilst requeueing errored outbox records")
+			return err
+		}
+		logger.Infof("requeued %d errored outbox records", rows)
+	}
+
+	if r.QuitSidecar {
+		if err := r.Quit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
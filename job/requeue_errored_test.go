@@ -0,0 +1,125 @@
+package job
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"inviqa/kafka-outbox-relay/config"
+	"inviqa/kafka-outbox-relay/job/test"
+	"inviqa/kafka-outbox-relay/outbox/data"
+	s "inviqa/kafka-outbox-relay/outbox/data/sql"
+	outboxtest "inviqa/kafka-outbox-relay/outbox/test"
+)
+
+func TestNewRequeueErroredWithDefaults(t *testing.T) {
+	cfg := &config.Config{
+		Requeue: config.Requeue{
+			DryRun:             true,
+			Topic:              "product",
+			MinId:              1,
+			MaxId:              2,
+			ErrorReasonPattern: "%oops%",
+		},
+	}
+
+	j := newRequeueErroredWithDefaults(data.DB{}, cfg)
+	if j == nil {
+		t.Fatal("received nil instead of requeue errored job")
+	}
+
+	exp := s.ErroredFilter{Topic: "product", MinId: 1, MaxId: 2, ErrorReasonPattern: "%oops%"}
+	if diff := deep.Equal(exp, j.filter); diff != nil {
+		t.Error(diff)
+	}
+
+	if !j.dryRun {
+		t.Error("expected the job to be a dry run")
+	}
+}
+
+func TestRequeueErrored_Execute(t *testing.T) {
+	ctx := context.Background()
+	repo := outboxtest.NewMockRepository()
+	repo.SetErroredCount(5)
+	cl := test.NewMockHttpClient()
+	j := newTestRequeueErrored(cl, repo, false)
+
+	if err := j.Execute(ctx); err != nil {
+		t.Errorf("unexpected error received: %s", err)
+	}
+
+	if diff := deep.Equal([]s.ErroredFilter{j.filter}, repo.RequeuedFilters()); diff != nil {
+		t.Error(diff)
+	}
+
+	if len(cl.SentReqs) > 0 {
+		t.Errorf("unexpected call to sidecar proxy /quitquitquit")
+	}
+}
+
+func TestRequeueErrored_ExecuteDryRun(t *testing.T) {
+	ctx := context.Background()
+	repo := outboxtest.NewMockRepository()
+	repo.SetErroredCount(5)
+	cl := test.NewMockHttpClient()
+	j := newTestRequeueErrored(cl, repo, true)
+
+	if err := j.Execute(ctx); err != nil {
+		t.Errorf("unexpected error received: %s", err)
+	}
+
+	if len(repo.RequeuedFilters()) > 0 {
+		t.Error("expected no errored messages to be requeued during a dry run")
+	}
+}
+
+func TestRequeueErrored_ExecuteWithSidecarProxyQuit(t *testing.T) {
+	ctx := context.Background()
+	repo := outboxtest.NewMockRepository()
+	cl := test.NewMockHttpClient()
+	j := newTestRequeueErrored(cl, repo, false)
+	j.EnableSideCarProxyQuit("http://localhost:9090")
+
+	if err := j.Execute(ctx); err != nil {
+		t.Errorf("unexpected error received: %s", err)
+	}
+
+	if cl.SentReqs["http://localhost:9090/quitquitquit"] == false {
+		t.Errorf("expected a call to sidecar proxy http://localhost:9090/quitquitquit")
+	}
+}
+
+func TestRequeueErrored_ExecuteWithRepoError(t *testing.T) {
+	for _, dryRun := range []bool{true, false} {
+		ctx := context.Background()
+		repo := outboxtest.NewMockRepository()
+		repo.ReturnErrors()
+		cl := test.NewMockHttpClient()
+		j := newTestRequeueErrored(cl, repo, dryRun)
+		j.EnableSideCarProxyQuit("http://localhost:9090")
+
+		if err := j.Execute(ctx); err == nil {
+			t.Error("expected an error, but got nil")
+		}
+
+		if len(cl.SentReqs) > 0 {
+			t.Errorf("unexpected call to sidecar proxy /quitquitquit")
+		}
+	}
+}
+
+func newTestRequeueErrored(cl *test.MockHttpClient, repo *outboxtest.MockRepository, dryRun bool) *requeueErrored {
+	return &requeueErrored{
+		SidecarQuitter: SidecarQuitter{
+			Client: cl,
+		},
+		requeuerFactory: func() erroredRequeuer {
+			return repo
+		},
+		filter: s.ErroredFilter{Topic: "product"},
+		dryRun: dryRun,
+		dbName: "db",
+	}
+}
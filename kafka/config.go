@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"crypto/tls"
+	"fmt"
 	"os"
 	"time"
 
@@ -9,6 +10,20 @@ import (
 )
 
 func NewSaramaConfig(tlsEnable bool, tlsSkipVerify bool) *sarama.Config {
+	if !tlsEnable {
+		return NewSaramaConfigWithTLS(nil)
+	}
+
+	// #nosec G402
+	// we suppress this in gosec because it believes that InsecureSkipVerify is true, but it depends on the parameter
+	// value passed into this func, which is dependent on environment configuration
+	return NewSaramaConfigWithTLS(&tls.Config{InsecureSkipVerify: tlsSkipVerify})
+}
+
+// NewSaramaConfigWithTLS creates a Sarama config that connects to Kafka using
+// the given TLS config, e.g. with a private CA or a client certificate. TLS is
+// disabled if tlsCfg is nil.
+func NewSaramaConfigWithTLS(tlsCfg *tls.Config) *sarama.Config {
 	cfg := sarama.NewConfig()
 
 	host, _ := os.Hostname()
@@ -18,17 +33,39 @@ func NewSaramaConfig(tlsEnable bool, tlsSkipVerify bool) *sarama.Config {
 	cfg.Producer.Return.Successes = true
 	cfg.Producer.Compression = sarama.CompressionGZIP
 	cfg.Producer.Partitioner = NewOutboxPartitioner
+	// batches are sent to Kafka all at once, so we only allow a single in-flight
+	// request per broker to make sure retries cannot reorder messages with the same key
+	cfg.Net.MaxOpenRequests = 1
 	cfg.Metadata.Retry.Max = 10
 	cfg.Metadata.Retry.Backoff = 2 * time.Second
 	cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
 
-	if tlsEnable {
+	if tlsCfg != nil {
 		cfg.Net.TLS.Enable = true
-		// #nosec G402
-		// we suppress this in gosec because it believes that InsecureSkipVerify is true, but it depends on the parameter
-		// value passed into this func, which is dependent on environment configuration
-		cfg.Net.TLS.Config = &tls.Config{InsecureSkipVerify: tlsSkipVerify}
+		cfg.Net.TLS.Config = tlsCfg
 	}
 
 	return cfg
 }
+
+// EnableTransactions configures cfg for an idempotent, transactional producer
+// using the given transactional ID. Each transactional ID must only ever be
+// used by one producer at a time, otherwise Kafka will fence the older one.
+func EnableTransactions(cfg *sarama.Config, transactionalId string) {
+	cfg.Producer.Idempotent = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Transaction.ID = transactionalId
+	cfg.Net.MaxOpenRequests = 1
+}
+
+// TransactionalId derives the transactional ID for a single producer from the
+// relay instance, the database it is publishing messages for and the index of
+// the worker that owns the producer. If prefix is empty, then the hostname is
+// used to identify the relay instance instead.
+func TransactionalId(prefix, dbName string, worker int) string {
+	if prefix == "" {
+		prefix, _ = os.Hostname()
+	}
+
+	return fmt.Sprintf("%s-%s-%d", prefix, dbName, worker)
+}
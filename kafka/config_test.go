@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"crypto/tls"
 	"os"
 	"testing"
 
@@ -30,8 +31,53 @@ func TestNewSaramaConfig(t *testing.T) {
 		t.Error("expected TLS verification to be enabled")
 	}
 
+	if cfg.Net.MaxOpenRequests != 1 {
+		t.Errorf("expected a single in-flight request per broker, but got %d", cfg.Net.MaxOpenRequests)
+	}
+
 	partitioner := cfg.Producer.Partitioner("foo")
 	if _, ok := partitioner.(OutboxPartitioner); !ok {
 		t.Error("expected kafka.OutboxPartitioner, but did not get one")
 	}
 }
+
+func TestEnableTransactions(t *testing.T) {
+	cfg := NewSaramaConfig(false, false)
+	EnableTransactions(cfg, "relay-db-0")
+
+	if cfg.Producer.Transaction.ID != "relay-db-0" {
+		t.Errorf("expected transactional ID to be 'relay-db-0', but got '%s'", cfg.Producer.Transaction.ID)
+	}
+
+	if !cfg.Producer.Idempotent {
+		t.Error("expected the producer to be idempotent")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected a valid transactional config, but got error: %s", err)
+	}
+}
+
+func TestTransactionalId(t *testing.T) {
+	if got := TransactionalId("relay", "orders", 2); got != "relay-orders-2" {
+		t.Errorf("expected 'relay-orders-2', but got '%s'", got)
+	}
+
+	host, _ := os.Hostname()
+	if got, exp := TransactionalId("", "orders", 0), host+"-orders-0"; got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+}
+
+func TestNewSaramaConfigWithTLS(t *testing.T) {
+	tlsCfg := &tls.Config{ServerName: "kafka"}
+	cfg := NewSaramaConfigWithTLS(tlsCfg)
+
+	if !cfg.Net.TLS.Enable || cfg.Net.TLS.Config != tlsCfg {
+		t.Error("expected TLS to be enabled with the given config")
+	}
+
+	if cfg := NewSaramaConfigWithTLS(nil); cfg.Net.TLS.Enable {
+		t.Error("expected TLS to be disabled")
+	}
+}
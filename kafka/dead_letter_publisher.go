@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"inviqa/kafka-outbox-relay/log"
+	"inviqa/kafka-outbox-relay/outbox"
+
+	"github.com/Shopify/sarama"
+)
+
+// DeadLetter is the envelope that is published to the dead-letter topic for
+// a message that has exhausted all of its publish attempts. It contains
+// everything needed to replay the original message from Kafka tooling.
+type DeadLetter struct {
+	OutboxId     uint   `json:"outbox_id"`
+	Topic        string `json:"topic"`
+	Key          string `json:"key"`
+	PartitionKey string `json:"partition_key"`
+	Headers      string `json:"headers"`
+	Payload      string `json:"payload"`
+	Tombstone    bool   `json:"tombstone,omitempty"`
+	ErrorReason  string `json:"error_reason"`
+	ErrorClass   string `json:"error_class"`
+	PushAttempts int    `json:"push_attempts"`
+}
+
+type DeadLetterPublisher struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func NewDeadLetterPublisher(kafkaHost []string, cfg *sarama.Config, topic string) DeadLetterPublisher {
+	return NewDeadLetterPublisherWithProducer(newProducer(cfg, kafkaHost), topic)
+}
+
+func NewDeadLetterPublisherWithProducer(prod sarama.SyncProducer, topic string) DeadLetterPublisher {
+	return DeadLetterPublisher{
+		producer: prod,
+		topic:    topic,
+	}
+}
+
+// PublishDeadLetter publishes the given message, wrapped in a DeadLetter
+// envelope, to the dead-letter topic. The message is published with its
+// original key, so that it ends up on a predictable partition.
+func (p DeadLetterPublisher) PublishDeadLetter(m *outbox.Message) error {
+	dl := DeadLetter{
+		OutboxId:     m.Id,
+		Topic:        m.Topic,
+		Key:          m.Key,
+		PartitionKey: m.PartitionKey,
+		Headers:      string(m.PayloadHeaders),
+		Payload:      string(m.PayloadJson),
+		Tombstone:    m.Tombstone,
+		// retries whilst Kafka is unavailable do not use up the publish
+		// attempts, but they are still attempts to publish the message
+		PushAttempts: m.PushAttempts + m.RetryAttempts + 1,
+	}
+	if m.ErrorReason != nil {
+		dl.ErrorReason = m.ErrorReason.Error()
+		dl.ErrorClass = string(outbox.ClassOf(m.ErrorReason))
+	}
+
+	val, err := json.Marshal(dl)
+	if err != nil {
+		return fmt.Errorf("error encoding dead-letter message: %w", err)
+	}
+
+	var mk sarama.Encoder
+	if m.Key != "" {
+		mk = newMessageKey(m.Key, m.PartitionKey)
+	}
+
+	partition, offset, err := p.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: p.topic,
+		Key:   mk,
+		Value: sarama.ByteEncoder(val),
+	})
+
+	if err != nil {
+		wrapErr := fmt.Errorf("error producing dead-letter message in Kafka: %w", err)
+		log.Logger.Error(wrapErr)
+		return wrapErr
+	}
+
+	log.Logger.Debugf("produced dead-letter message in Kafka (topic: %s, partition: %d, offset: %d)", p.topic, partition, offset)
+
+	return nil
+}
+
+func (p DeadLetterPublisher) Close() error {
+	return p.producer.Close()
+}
@@ -0,0 +1,105 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"inviqa/kafka-outbox-relay/kafka/test"
+	"inviqa/kafka-outbox-relay/outbox"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/go-test/deep"
+)
+
+func TestNewDeadLetterPublisherWithProducer(t *testing.T) {
+	deep.CompareUnexportedFields = true
+	deep.MaxDepth = 2
+	defer func() {
+		deep.CompareUnexportedFields = false
+		deep.MaxDepth = 10
+	}()
+
+	prod := mocks.NewSyncProducer(t, NewSaramaConfig(false, false))
+	exp := DeadLetterPublisher{
+		producer: prod,
+		topic:    "deadLetters",
+	}
+
+	if diff := deep.Equal(exp, NewDeadLetterPublisherWithProducer(prod, "deadLetters")); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestDeadLetterPublisher_PublishDeadLetter(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	pub := NewDeadLetterPublisherWithProducer(prod, "deadLetters")
+
+	msg := &outbox.Message{
+		Id:             7,
+		PayloadJson:    []byte(`{"foo":"bar"}`),
+		PayloadHeaders: []byte(`{"x-event-id":"id"}`),
+		Topic:          "productUpdate",
+		Key:            "bar",
+		PartitionKey:   "foo",
+		PushAttempts:   2,
+		ErrorReason:    errors.New("oops"),
+	}
+
+	if err := pub.PublishDeadLetter(msg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := &sarama.ProducerMessage{
+		Topic: "deadLetters",
+		Key:   newMessageKey("bar", "foo"),
+		Value: sarama.ByteEncoder(`{"outbox_id":7,"topic":"productUpdate","key":"bar","partition_key":"foo","headers":"{\"x-event-id\":\"id\"}","payload":"{\"foo\":\"bar\"}","error_reason":"oops","error_class":"unclassified","push_attempts":3}`),
+	}
+
+	if err := prod.MessageWasProduced("deadLetters", exp); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeadLetterPublisher_PublishDeadLetterAfterRetries(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	pub := NewDeadLetterPublisherWithProducer(prod, "deadLetters")
+
+	msg := &outbox.Message{
+		Id:            8,
+		PayloadJson:   []byte(`{"foo":"bar"}`),
+		Topic:         "productUpdate",
+		RetryAttempts: 19,
+		ErrorReason:   outbox.NewRetriableError(errors.New("kafka: client has run out of available brokers")),
+	}
+
+	if err := pub.PublishDeadLetter(msg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := &sarama.ProducerMessage{
+		Topic: "deadLetters",
+		Value: sarama.ByteEncoder(`{"outbox_id":8,"topic":"productUpdate","key":"","partition_key":"","headers":"","payload":"{\"foo\":\"bar\"}","error_reason":"kafka: client has run out of available brokers","error_class":"retriable","push_attempts":20}`),
+	}
+
+	if err := prod.MessageWasProduced("deadLetters", exp); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeadLetterPublisher_PublishDeadLetterWithSendError(t *testing.T) {
+	prod := mocks.NewSyncProducer(t, NewSaramaConfig(false, false))
+	pub := NewDeadLetterPublisherWithProducer(prod, "deadLetters")
+
+	prod.ExpectSendMessageAndFail(errors.New("oops"))
+
+	msg := &outbox.Message{
+		Id:          2,
+		PayloadJson: []byte(`{"payload"}`),
+		Topic:       "productUpdate",
+	}
+
+	if err := pub.PublishDeadLetter(msg); err == nil {
+		t.Error("expected an error but got nil")
+	}
+}
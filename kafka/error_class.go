@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"inviqa/kafka-outbox-relay/outbox"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	// permanentErrors will occur again on every attempt to publish the message
+	permanentErrors = []error{
+		sarama.ErrMessageSizeTooLarge,
+		sarama.ErrInvalidMessage,
+		sarama.ErrInvalidMessageSize,
+		sarama.ErrInvalidTopic,
+		sarama.ErrInvalidRecord,
+		// the topic does not exist, and was not created automatically when
+		// the producer fetched its metadata
+		sarama.ErrUnknownTopicOrPartition,
+	}
+
+	// retriableErrors are caused by the Kafka cluster being temporarily
+	// unavailable, rather than by the message being published
+	retriableErrors = []error{
+		sarama.ErrOutOfBrokers,
+		sarama.ErrNotConnected,
+		sarama.ErrClosedClient,
+		sarama.ErrShuttingDown,
+		sarama.ErrBrokerNotAvailable,
+		sarama.ErrLeaderNotAvailable,
+		sarama.ErrNotLeaderForPartition,
+		sarama.ErrRequestTimedOut,
+		sarama.ErrNetworkException,
+		sarama.ErrNotEnoughReplicas,
+		sarama.ErrNotEnoughReplicasAfterAppend,
+		sarama.ErrKafkaStorageError,
+		context.DeadlineExceeded,
+	}
+)
+
+// classifyError wraps err in an outbox.ClassifiedError if it is known to be
+// either permanent or retriable, otherwise err is returned unchanged.
+func classifyError(err error) error {
+	for _, e := range permanentErrors {
+		if errors.Is(err, e) {
+			return outbox.NewPermanentError(err)
+		}
+	}
+
+	for _, e := range retriableErrors {
+		if errors.Is(err, e) {
+			return outbox.NewRetriableError(err)
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return outbox.NewRetriableError(err)
+	}
+
+	return err
+}
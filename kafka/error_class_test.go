@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"inviqa/kafka-outbox-relay/outbox"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want outbox.ErrorClass
+	}{
+		{
+			name: "message too large is permanent",
+			err:  fmt.Errorf("error producing message in Kafka: %w", sarama.ErrMessageSizeTooLarge),
+			want: outbox.Permanent,
+		},
+		{
+			name: "invalid topic is permanent",
+			err:  sarama.ErrInvalidTopic,
+			want: outbox.Permanent,
+		},
+		{
+			name: "out of brokers is retriable",
+			err:  fmt.Errorf("error producing message in Kafka: %w", sarama.ErrOutOfBrokers),
+			want: outbox.Retriable,
+		},
+		{
+			name: "request timed out is retriable",
+			err:  sarama.ErrRequestTimedOut,
+			want: outbox.Retriable,
+		},
+		{
+			name: "context deadline exceeded is retriable",
+			err:  context.DeadlineExceeded,
+			want: outbox.Retriable,
+		},
+		{
+			name: "network error is retriable",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			want: outbox.Retriable,
+		},
+		{
+			name: "unknown topic is permanent",
+			err:  fmt.Errorf("error producing message in Kafka: %w", sarama.ErrUnknownTopicOrPartition),
+			want: outbox.Permanent,
+		},
+		{
+			name: "unknown error is unclassified",
+			err:  errors.New("oops"),
+			want: outbox.Unclassified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError(tt.err)
+			if class := outbox.ClassOf(got); class != tt.want {
+				t.Errorf("expected error class %s, but got %s", tt.want, class)
+			}
+
+			if !errors.Is(got, tt.err) {
+				t.Error("expected the classified error to wrap the original error")
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// murmur2 is the 32 bit murmur2 hash used by the Java Kafka client to choose
+// the partition for each key, so that the relay can place keys on the same
+// partitions as Java producers.
+type murmur2 struct {
+	data []byte
+}
+
+func newMurmur2() hash.Hash32 {
+	return &murmur2{}
+}
+
+func (m *murmur2) Write(p []byte) (int, error) {
+	m.data = append(m.data, p...)
+	return len(p), nil
+}
+
+func (m *murmur2) Sum(b []byte) []byte {
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, m.Sum32())
+
+	return append(b, sum...)
+}
+
+func (m *murmur2) Reset() {
+	m.data = m.data[:0]
+}
+
+func (m *murmur2) Size() int {
+	return 4
+}
+
+func (m *murmur2) BlockSize() int {
+	return 4
+}
+
+// Sum32 returns the hash of the data written so far, which is a port of
+// org.apache.kafka.common.utils.Utils.murmur2.
+func (m *murmur2) Sum32() uint32 {
+	const (
+		seed = 0x9747b28c
+		mix  = 0x5bd1e995
+		r    = 24
+	)
+
+	length := len(m.data)
+	h := uint32(seed) ^ uint32(length)
+
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(m.data[i:])
+		k *= mix
+		k ^= k >> r
+		k *= mix
+		h *= mix
+		h ^= k
+	}
+
+	tail := m.data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= mix
+	}
+
+	h ^= h >> 13
+	h *= mix
+	h ^= h >> 15
+
+	return h
+}
@@ -0,0 +1,27 @@
+package kafka
+
+import (
+	"testing"
+)
+
+func TestMurmur2_Sum32(t *testing.T) {
+	// the hashes returned by the Java client for the same data
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+
+	h := newMurmur2()
+	for data, exp := range tests {
+		h.Reset()
+		_, _ = h.Write([]byte(data))
+
+		if got := int32(h.Sum32()); got != exp {
+			t.Errorf("expected the hash of %s to be %d, but got %d", data, exp, got)
+		}
+	}
+}
@@ -1,9 +1,118 @@
 package kafka
 
 import (
+	"fmt"
+	"hash/crc32"
+	"sync"
+
+	"inviqa/kafka-outbox-relay/outbox"
+
 	"github.com/Shopify/sarama"
 )
 
+// The strategies used to choose the partition for each message.
+const (
+	// PartitionerFNV hashes each key with FNV-1a, as sarama does by default.
+	PartitionerFNV = "fnv"
+	// PartitionerMurmur2 hashes each key with murmur2, placing keys on the
+	// same partitions as the Java client.
+	PartitionerMurmur2 = "murmur2"
+	// PartitionerCRC32 hashes each key with CRC32, placing keys on the same
+	// partitions as the librdkafka consistent partitioner.
+	PartitionerCRC32 = "crc32"
+	// PartitionerRoundRobin sends messages without a key to each partition in
+	// turn, rather than a random partition, whereas messages with a key are
+	// hashed with FNV-1a so that they stay in order.
+	PartitionerRoundRobin = "round-robin"
+)
+
+// NewOutboxPartitionerConstructor creates a partitioner for each topic that
+// messages are published to, which partitions each message with an
+// OutboxPartitioner using the strategy that strategy returns for the message's
+// logical topic in the outbox, before any topic rewriting.
+func NewOutboxPartitionerConstructor(strategy func(topic string) string) sarama.PartitionerConstructor {
+	return func(topic string) sarama.Partitioner {
+		return &strategyPartitioner{
+			topic:        topic,
+			strategy:     strategy,
+			partitioners: map[string]sarama.Partitioner{},
+		}
+	}
+}
+
+// strategyPartitioner chooses the partitioning strategy for each message from
+// its logical topic, so that the strategies match the same topics as the
+// routes and serializers, whatever topic the message is published to.
+type strategyPartitioner struct {
+	topic    string
+	strategy func(topic string) string
+
+	mu           sync.Mutex
+	partitioners map[string]sarama.Partitioner
+}
+
+func (s *strategyPartitioner) Partition(message *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	logical := s.topic
+	if m, ok := message.Metadata.(*outbox.Message); ok {
+		logical = m.Topic
+	}
+	strategy := s.strategy(logical)
+
+	s.mu.Lock()
+	p, ok := s.partitioners[strategy]
+	if !ok {
+		p = NewOutboxPartitionerWithCustomPartitioner(s.topic, newKeyPartitioner(strategy, s.topic))
+		s.partitioners[strategy] = p
+	}
+	s.mu.Unlock()
+
+	return p.Partition(message, numPartitions)
+}
+
+func (s *strategyPartitioner) RequiresConsistency() bool {
+	return true
+}
+
+// newKeyPartitioner creates the partitioner used to choose the partition for
+// each message key using the strategy, which is FNV-1a if the strategy is not
+// known.
+func newKeyPartitioner(strategy, topic string) sarama.Partitioner {
+	switch strategy {
+	case PartitionerMurmur2:
+		return sarama.NewCustomPartitioner(sarama.WithAbsFirst(), sarama.WithCustomHashFunction(newMurmur2))(topic)
+	case PartitionerCRC32:
+		return crc32Partitioner{random: sarama.NewRandomPartitioner(topic)}
+	case PartitionerRoundRobin:
+		return sarama.NewCustomPartitioner(sarama.WithCustomFallbackPartitioner(sarama.NewRoundRobinPartitioner(topic)))(topic)
+	default:
+		return sarama.NewHashPartitioner(topic)
+	}
+}
+
+// crc32Partitioner chooses the partition for each key from its unsigned CRC32
+// checksum, modulus the number of partitions, and a random partition for
+// messages without a key.
+type crc32Partitioner struct {
+	random sarama.Partitioner
+}
+
+func (c crc32Partitioner) Partition(message *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	if message.Key == nil {
+		return c.random.Partition(message, numPartitions)
+	}
+
+	key, err := message.Key.Encode()
+	if err != nil {
+		return -1, err
+	}
+
+	return int32(crc32.ChecksumIEEE(key) % uint32(numPartitions)), nil
+}
+
+func (c crc32Partitioner) RequiresConsistency() bool {
+	return true
+}
+
 type OutboxPartitioner struct {
 	topic           string
 	hashPartitioner sarama.Partitioner
@@ -20,7 +129,19 @@ func NewOutboxPartitionerWithCustomPartitioner(topic string, p sarama.Partitione
 	}
 }
 
+// Partition returns the message's explicit partition when it has one, which
+// must be one of the topic's partitions, otherwise the partition is chosen by
+// hashing the message's key.
 func (o OutboxPartitioner) Partition(message *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	if m, ok := message.Metadata.(*outbox.Message); ok && m.Partition.Valid {
+		if m.Partition.Int32 < 0 || m.Partition.Int32 >= numPartitions {
+			// the topic will not have the partition, however many times we try
+			return -1, outbox.NewPermanentError(fmt.Errorf("the message's partition %d is out of range for the topic %s, which has %d partitions", m.Partition.Int32, message.Topic, numPartitions))
+		}
+
+		return m.Partition.Int32, nil
+	}
+
 	mk, ok := message.Key.(MessageKey)
 	if !ok {
 		return o.hashPartitioner.Partition(message, numPartitions)
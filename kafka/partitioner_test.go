@@ -1,10 +1,13 @@
 package kafka
 
 import (
+	"database/sql"
 	"errors"
 	"reflect"
 	"testing"
 
+	"inviqa/kafka-outbox-relay/outbox"
+
 	"github.com/Shopify/sarama"
 	"github.com/go-test/deep"
 )
@@ -112,6 +115,126 @@ func TestOutboxPartitioner_Partition(t *testing.T) {
 			t.Error("expected an error but got nil")
 		}
 	})
+
+	t.Run("explicit partition is used instead of the key", func(t *testing.T) {
+		t.Parallel()
+		fp := newFakeHashPartitioner(false)
+		fp.partitionToReturn = 1
+		ob := NewOutboxPartitionerWithCustomPartitioner("product", fp)
+		msg := &sarama.ProducerMessage{
+			Key:      newMessageKey("foo", "bar"),
+			Metadata: &outbox.Message{Partition: sql.NullInt32{Int32: 4, Valid: true}},
+		}
+
+		got, err := ob.Partition(msg, 5)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+
+		if got != 4 {
+			t.Errorf("expected partition 4 but got %d", got)
+		}
+
+		if fp.recvdMessageKey != "" {
+			t.Errorf("expected the hashPartitioner not to be used, but it received the key '%s'", fp.recvdMessageKey)
+		}
+	})
+
+	t.Run("key is used when the message has no explicit partition", func(t *testing.T) {
+		t.Parallel()
+		fp := newFakeHashPartitioner(false)
+		fp.partitionToReturn = 1
+		ob := NewOutboxPartitionerWithCustomPartitioner("product", fp)
+		msg := &sarama.ProducerMessage{
+			Key:      newMessageKey("foo", ""),
+			Metadata: &outbox.Message{},
+		}
+
+		got, err := ob.Partition(msg, 5)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+
+		if got != 1 {
+			t.Errorf("expected partition 1 but got %d", got)
+		}
+	})
+
+	t.Run("permanent error is returned for an out of range partition", func(t *testing.T) {
+		t.Parallel()
+		ob := NewOutboxPartitionerWithCustomPartitioner("product", newFakeHashPartitioner(false))
+
+		for _, ptn := range []int32{-1, 5} {
+			msg := &sarama.ProducerMessage{
+				Metadata: &outbox.Message{Partition: sql.NullInt32{Int32: ptn, Valid: true}},
+			}
+
+			_, err := ob.Partition(msg, 5)
+			if class := outbox.ClassOf(err); err == nil || class != outbox.Permanent {
+				t.Errorf("expected a permanent error for partition %d, but got %v", ptn, err)
+			}
+		}
+	})
+}
+
+func TestNewOutboxPartitionerConstructor(t *testing.T) {
+	// each topic is named after the strategy used for it
+	construct := NewOutboxPartitionerConstructor(func(topic string) string {
+		return topic
+	})
+
+	// the partitions that the Java client, librdkafka and sarama choose for
+	// the "foobar" key in a topic with 16 partitions
+	tests := map[string]int32{
+		"murmur2":     14,
+		"crc32":       5,
+		"fnv":         8,
+		"round-robin": 8,
+	}
+	for topic, exp := range tests {
+		msg := &sarama.ProducerMessage{Key: newMessageKey("bar", "foobar")}
+
+		got, err := construct(topic).Partition(msg, 16)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+
+		if got != exp {
+			t.Errorf("expected partition %d for the %s topic, but got %d", exp, topic, got)
+		}
+	}
+
+	ob := construct("round-robin")
+	for _, exp := range []int32{0, 1, 2, 0} {
+		if got, _ := ob.Partition(&sarama.ProducerMessage{}, 3); got != exp {
+			t.Errorf("expected the message without a key to be sent to partition %d, but got %d", exp, got)
+		}
+	}
+}
+
+func TestNewOutboxPartitionerConstructor_MatchesLogicalTopic(t *testing.T) {
+	construct := NewOutboxPartitionerConstructor(func(topic string) string {
+		if topic == "orders" {
+			return PartitionerMurmur2
+		}
+		return PartitionerFNV
+	})
+
+	// the orders topic is published to staging.orders after it is rewritten
+	msg := &sarama.ProducerMessage{
+		Topic:    "staging.orders",
+		Key:      newMessageKey("bar", "foobar"),
+		Metadata: &outbox.Message{Topic: "orders"},
+	}
+
+	got, err := construct("staging.orders").Partition(msg, 16)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got != 14 {
+		t.Errorf("expected the murmur2 partition 14 for the logical orders topic, but got %d", got)
+	}
 }
 
 func TestOutboxPartitioner_RequiresConsistency(t *testing.T) {
@@ -2,8 +2,12 @@ package kafka
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 
 	"inviqa/kafka-outbox-relay/log"
 	"inviqa/kafka-outbox-relay/outbox"
@@ -13,6 +17,21 @@ import (
 
 type Publisher struct {
 	producer sarama.SyncProducer
+	// opts is a pointer so that publishers can be compared with each other
+	opts *PublisherOptions
+}
+
+// PublisherOptions holds the optional settings that control how each outbox
+// message is turned into the Kafka message that is published.
+type PublisherOptions struct {
+	// Topics rewrites the topic of each message, when it is not nil.
+	Topics *TopicRewriter
+	// Serializers converts the payload of the messages for some topics, e.g.
+	// into Avro. Any other payloads are published as they are.
+	Serializers Serializers
+	// Headers adds the relay's own headers to each message, when it is not
+	// nil.
+	Headers *RelayHeaders
 }
 
 func NewPublisher(kafkaHost []string, cfg *sarama.Config) Publisher {
@@ -25,6 +44,15 @@ func NewPublisherWithProducer(prod sarama.SyncProducer) Publisher {
 	}
 }
 
+// NewPublisherWithOptions creates a Publisher that uses the options to build
+// each message before it is published.
+func NewPublisherWithOptions(prod sarama.SyncProducer, opts PublisherOptions) Publisher {
+	return Publisher{
+		producer: prod,
+		opts:     &opts,
+	}
+}
+
 func newProducer(cfg *sarama.Config, kafkaHosts []string) sarama.SyncProducer {
 	producer, err := sarama.NewSyncProducer(kafkaHosts, cfg)
 	if err != nil {
@@ -35,13 +63,123 @@ func newProducer(cfg *sarama.Config, kafkaHosts []string) sarama.SyncProducer {
 }
 
 func (p Publisher) PublishMessage(m *outbox.Message) error {
-	headers, err := p.createRecordHeaders(m.PayloadHeaders)
+	msg, err := p.newProducerMessage(m)
+	if err != nil {
+		log.Logger.Error(err)
+		return err
+	}
+
+	partition, offset, err := p.producer.SendMessage(msg)
+
 	if err != nil {
-		wrapErr := fmt.Errorf("error unmarshalling message headers for publishing to Kafka: %w", err)
+		wrapErr := classifyError(fmt.Errorf("error producing message in Kafka: %w", err))
 		log.Logger.Error(wrapErr)
 		return wrapErr
 	}
 
+	log.Logger.Debugf("produced message in Kafka (topic: %s, partition: %d, offset: %d)", msg.Topic, partition, offset)
+
+	return nil
+}
+
+// PublishBatch sends all the given messages to Kafka at once, rather than waiting
+// for each message to be acknowledged before sending the next one. Any message
+// that could not be produced has its ErrorReason set, and the first error that
+// was encountered is returned.
+func (p Publisher) PublishBatch(msgs []*outbox.Message) error {
+	var firstErr error
+	pms := make([]*sarama.ProducerMessage, 0, len(msgs))
+	for _, m := range msgs {
+		pm, err := p.newProducerMessage(m)
+		if err != nil {
+			log.Logger.Error(err)
+			m.ErrorReason = err
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		pms = append(pms, pm)
+	}
+
+	if len(pms) == 0 {
+		return firstErr
+	}
+
+	err := p.producer.SendMessages(pms)
+	if err == nil {
+		log.Logger.Debugf("produced %d messages in Kafka", len(pms))
+		return firstErr
+	}
+
+	var prodErrs sarama.ProducerErrors
+	if !errors.As(err, &prodErrs) {
+		// the producer did not tell us which messages failed, so we have to
+		// assume that none of them were produced successfully
+		wrapErr := classifyError(fmt.Errorf("error producing messages in Kafka: %w", err))
+		log.Logger.Error(wrapErr)
+		for _, pm := range pms {
+			pm.Metadata.(*outbox.Message).ErrorReason = wrapErr
+		}
+		return wrapErr
+	}
+
+	for _, prodErr := range prodErrs {
+		wrapErr := classifyError(fmt.Errorf("error producing message in Kafka: %w", prodErr.Err))
+		log.Logger.Error(wrapErr)
+		prodErr.Msg.Metadata.(*outbox.Message).ErrorReason = wrapErr
+		if firstErr == nil {
+			firstErr = wrapErr
+		}
+	}
+
+	return firstErr
+}
+
+// TraceContext returns whether the distributed trace headers are added to each
+// message, so that they only need to be created when they are used.
+func (p Publisher) TraceContext() bool {
+	return p.opts != nil && p.opts.Headers != nil && p.opts.Headers.TraceContext
+}
+
+func (p Publisher) Close() error {
+	return p.producer.Close()
+}
+
+func (p Publisher) newProducerMessage(m *outbox.Message) (*sarama.ProducerMessage, error) {
+	var opts PublisherOptions
+	if p.opts != nil {
+		opts = *p.opts
+	}
+
+	if m.Tombstone && m.Key == "" {
+		// a tombstone deletes the messages with its key, so it is never valid
+		// without one
+		return nil, outbox.NewPermanentError(fmt.Errorf("the message is a tombstone without a key, so there is nothing for it to delete"))
+	}
+
+	topic, err := opts.Topics.Rewrite(m.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	// a tombstone is published without a value, whatever its payload
+	var value sarama.Encoder
+	if !m.Tombstone {
+		value, err = p.encodePayload(opts, topic, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	headers, err := p.createRecordHeaders(m.PayloadHeaders)
+	if err != nil {
+		// the headers will never be valid, however many times we try
+		return nil, outbox.NewPermanentError(fmt.Errorf("error creating message headers for publishing to Kafka: %w", err))
+	}
+	headers = opts.Headers.add(headers, m)
+
 	// if there is no Key value on the message then we do not want to
 	// set any message key on the sarama.ProducerMessage, regardless of
 	// whether there was a PartitionKey, which is an optional field
@@ -52,28 +190,39 @@ func (p Publisher) PublishMessage(m *outbox.Message) error {
 		mk = newMessageKey(m.Key, m.PartitionKey)
 	}
 
-	partition, offset, err := p.producer.SendMessage(&sarama.ProducerMessage{
-		Topic:   m.Topic,
+	return &sarama.ProducerMessage{
+		Topic:   topic,
 		Headers: headers,
-		Value:   sarama.ByteEncoder(m.PayloadJson),
+		Value:   value,
 		Key:     mk,
-	})
+		// the partitioner uses the message to find its explicit partition
+		Metadata: m,
+	}, nil
+}
 
-	if err != nil {
-		wrapErr := fmt.Errorf("error producing message in Kafka: %w", err)
-		log.Logger.Error(wrapErr)
-		return wrapErr
+// encodePayload returns the value of the message published to the topic, which
+// is its payload, converted by the serializer for its logical topic if it has
+// one.
+func (p Publisher) encodePayload(opts PublisherOptions, topic string, m *outbox.Message) (sarama.Encoder, error) {
+	ser := opts.Serializers.For(m.Topic)
+	if ser == nil {
+		return sarama.ByteEncoder(m.PayloadJson), nil
 	}
 
-	log.Logger.Debugf("produced message in Kafka (topic: %s, partition: %d, offset: %d)", m.Topic, partition, offset)
-
-	return nil
-}
+	value, err := ser.Serialize(topic, m.PayloadJson)
+	if err != nil {
+		return nil, err
+	}
 
-func (p Publisher) Close() error {
-	return p.producer.Close()
+	return sarama.ByteEncoder(value), nil
 }
 
+// createRecordHeaders converts the JSON object of headers into record headers,
+// sorted by key. Strings are used as they are, numbers and booleans are
+// converted into their JSON text, a null becomes a header without a value, and
+// an array adds the header once for each of its values, in order. Binary
+// values are wrapped in an object containing them in base64, e.g.
+// {"signature": {"base64": "3q2+7w=="}}. Any other value returns an error.
 func (p Publisher) createRecordHeaders(headers []byte) ([]sarama.RecordHeader, error) {
 	emptyJson := bytes.Compare(headers, []byte("{}")) == 0
 	if headers == nil || len(headers) == 0 || emptyJson {
@@ -89,19 +238,62 @@ func (p Publisher) createRecordHeaders(headers []byte) ([]sarama.RecordHeader, e
 		return nil, err
 	}
 
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
 	var recs []sarama.RecordHeader
-	for k, v := range h {
-		rec := sarama.RecordHeader{
-			Key: []byte(k),
+	for _, k := range keys {
+		values, ok := h[k].([]any)
+		if !ok {
+			values = []any{h[k]}
 		}
 
-		if str, ok := v.(string); ok {
-			rec.Value = []byte(str)
-		} else if num, ok := v.(json.Number); ok {
-			rec.Value = []byte(string(num))
+		for _, v := range values {
+			val, err := headerValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("the value of the %s header is not valid: %w", k, err)
+			}
+
+			recs = append(recs, sarama.RecordHeader{
+				Key:   []byte(k),
+				Value: val,
+			})
 		}
-		recs = append(recs, rec)
 	}
 
 	return recs, nil
 }
+
+// headerValue converts a single JSON header value into the bytes of a record
+// header.
+func headerValue(v any) ([]byte, error) {
+	switch val := v.(type) {
+	case string:
+		return []byte(val), nil
+	case json.Number:
+		return []byte(val), nil
+	case bool:
+		return []byte(strconv.FormatBool(val)), nil
+	case nil:
+		return nil, nil
+	case map[string]any:
+		enc, ok := val["base64"].(string)
+		if !ok || len(val) != 1 {
+			return nil, fmt.Errorf("an object must only contain a base64 string, e.g. {\"base64\": \"3q2+7w==\"}")
+		}
+
+		b, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode the base64 value: %w", err)
+		}
+
+		return b, nil
+	case []any:
+		return nil, fmt.Errorf("arrays cannot be nested within an array")
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T", v)
+	}
+}
@@ -1,8 +1,10 @@
 package kafka
 
 import (
+	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"inviqa/kafka-outbox-relay/kafka/test"
 	"inviqa/kafka-outbox-relay/outbox"
@@ -10,6 +12,7 @@ import (
 	"github.com/Shopify/sarama"
 	"github.com/Shopify/sarama/mocks"
 	"github.com/go-test/deep"
+	"github.com/google/uuid"
 )
 
 func TestNewPublisherWithProducer(t *testing.T) {
@@ -30,6 +33,18 @@ func TestNewPublisherWithProducer(t *testing.T) {
 	}
 }
 
+func TestPublisher_TraceContext(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	if NewPublisherWithProducer(prod).TraceContext() {
+		t.Error("expected no trace context without any relay headers")
+	}
+
+	pub := NewPublisherWithOptions(prod, PublisherOptions{Headers: &RelayHeaders{TraceContext: true}})
+	if !pub.TraceContext() {
+		t.Error("expected the trace context to be added when it is enabled")
+	}
+}
+
 func TestPublisher_PublishMessage(t *testing.T) {
 	prod := test.NewMockSyncProducer()
 	pub := NewPublisherWithProducer(prod)
@@ -56,8 +71,9 @@ func TestPublisher_PublishMessage(t *testing.T) {
 				Value: []byte("id"),
 			},
 		},
-		Key:   newMessageKey("bar", "foo"),
-		Value: sarama.ByteEncoder(`{"payload"}`),
+		Key:      newMessageKey("bar", "foo"),
+		Value:    sarama.ByteEncoder(`{"payload"}`),
+		Metadata: msg,
 	}
 
 	if err := prod.MessageWasProduced("productUpdate", exp); err != nil {
@@ -82,10 +98,11 @@ func TestPublisher_PublishMessageWithNilHeaders(t *testing.T) {
 	}
 
 	exp := &sarama.ProducerMessage{
-		Topic:   "productUpdate",
-		Headers: []sarama.RecordHeader{},
-		Value:   sarama.ByteEncoder(`{"payload"}`),
-		Key:     newMessageKey("bar", "buzz"),
+		Topic:    "productUpdate",
+		Headers:  []sarama.RecordHeader{},
+		Value:    sarama.ByteEncoder(`{"payload"}`),
+		Metadata: msg,
+		Key:      newMessageKey("bar", "buzz"),
 	}
 
 	if err := prod.MessageWasProduced("productUpdate", exp); err != nil {
@@ -110,10 +127,11 @@ func TestPublisher_PublishMessageWithEmptyHeadersAndKey(t *testing.T) {
 		}
 
 		exp := &sarama.ProducerMessage{
-			Topic:   "productUpdate",
-			Headers: []sarama.RecordHeader{},
-			Value:   sarama.ByteEncoder(`{"payload"}`),
-			Key:     nil,
+			Topic:    "productUpdate",
+			Headers:  []sarama.RecordHeader{},
+			Value:    sarama.ByteEncoder(`{"payload"}`),
+			Metadata: msg,
+			Key:      nil,
 		}
 
 		if err := prod.MessageWasProduced("productUpdate", exp); err != nil {
@@ -145,7 +163,43 @@ func TestPublisher_PublishMessageWithIntHeaderValue(t *testing.T) {
 				Value: []byte("1"),
 			},
 		},
-		Value: sarama.ByteEncoder(`{"payload"}`),
+		Value:    sarama.ByteEncoder(`{"payload"}`),
+		Metadata: msg,
+	}
+
+	if err := prod.MessageWasProduced("productUpdate", exp); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPublisher_PublishMessageWithHeaderValueTypes(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	pub := NewPublisherWithProducer(prod)
+
+	msg := &outbox.Message{
+		Id:             1,
+		PayloadJson:    []byte(`{"payload"}`),
+		PayloadHeaders: []byte(`{"retry":true,"trace":null,"tag":["a",2,false],"sig":{"base64":"3q2+7w=="},"ratio":1.5}`),
+		Topic:          "productUpdate",
+	}
+
+	if err := pub.PublishMessage(msg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := &sarama.ProducerMessage{
+		Topic: "productUpdate",
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("ratio"), Value: []byte("1.5")},
+			{Key: []byte("retry"), Value: []byte("true")},
+			{Key: []byte("sig"), Value: []byte{0xde, 0xad, 0xbe, 0xef}},
+			{Key: []byte("tag"), Value: []byte("a")},
+			{Key: []byte("tag"), Value: []byte("2")},
+			{Key: []byte("tag"), Value: []byte("false")},
+			{Key: []byte("trace"), Value: nil},
+		},
+		Value:    sarama.ByteEncoder(`{"payload"}`),
+		Metadata: msg,
 	}
 
 	if err := prod.MessageWasProduced("productUpdate", exp); err != nil {
@@ -153,6 +207,39 @@ func TestPublisher_PublishMessageWithIntHeaderValue(t *testing.T) {
 	}
 }
 
+func TestPublisher_PublishMessageWithUnsupportedHeaderValues(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	pub := NewPublisherWithProducer(prod)
+
+	tests := map[string]string{
+		"object":              `{"foo":{"bar":"baz"}}`,
+		"object with base64":  `{"foo":{"base64":"3q2+7w==","type":"sig"}}`,
+		"invalid base64":      `{"foo":{"base64":"not base64!"}}`,
+		"non-string base64":   `{"foo":{"base64":123}}`,
+		"nested array":        `{"foo":[["bar"]]}`,
+		"object in an array":  `{"foo":["bar",{"baz":1}]}`,
+		"headers not objects": `["foo"]`,
+	}
+
+	for name, headers := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := pub.PublishMessage(&outbox.Message{
+				Id:             1,
+				PayloadJson:    []byte(`{"payload"}`),
+				PayloadHeaders: []byte(headers),
+				Topic:          "productUpdate",
+			})
+			if err == nil {
+				t.Fatal("expected an error but got nil")
+			}
+
+			if class := outbox.ClassOf(err); class != outbox.Permanent {
+				t.Errorf("expected a permanent error, but got %s", class)
+			}
+		})
+	}
+}
+
 func TestPublisher_PublishMessageWithHeadersUnmarshalError(t *testing.T) {
 	prod := test.NewMockSyncProducer()
 	pub := NewPublisherWithProducer(prod)
@@ -164,8 +251,192 @@ func TestPublisher_PublishMessageWithHeadersUnmarshalError(t *testing.T) {
 		Topic:          "productUpdate",
 	}
 
-	if err := pub.PublishMessage(msg); err == nil {
-		t.Error("expected an error but got nil")
+	err := pub.PublishMessage(msg)
+	if err == nil {
+		t.Fatal("expected an error but got nil")
+	}
+
+	if class := outbox.ClassOf(err); class != outbox.Permanent {
+		t.Errorf("expected a permanent error, but got %s", class)
+	}
+}
+
+func TestPublisher_PublishMessageWithTopicRewriter(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	pub := NewPublisherWithOptions(prod, PublisherOptions{
+		Topics: &TopicRewriter{Blocked: []string{"internal.*"}, Prefix: "staging."},
+	})
+
+	msg := &outbox.Message{
+		Id:          1,
+		PayloadJson: []byte(`{"payload"}`),
+		Topic:       "productUpdate",
+	}
+
+	if err := pub.PublishMessage(msg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := &sarama.ProducerMessage{
+		Topic:    "staging.productUpdate",
+		Headers:  []sarama.RecordHeader{},
+		Value:    sarama.ByteEncoder(`{"payload"}`),
+		Metadata: msg,
+	}
+
+	if err := prod.MessageWasProduced("staging.productUpdate", exp); err != nil {
+		t.Error(err)
+	}
+
+	err := pub.PublishMessage(&outbox.Message{Id: 2, Topic: "internal.audit"})
+	if class := outbox.ClassOf(err); err == nil || class != outbox.Permanent {
+		t.Errorf("expected a permanent error for the blocked topic, but got %v", err)
+	}
+}
+
+func TestPublisher_PublishMessageWithSerializer(t *testing.T) {
+	reg := test.NewMockSchemaRegistry()
+	defer reg.Close()
+	reg.RegisterSchema("staging.orders-value", 7, SchemaTypeJSONSchema, `{"type": "object"}`)
+
+	prod := test.NewMockSyncProducer()
+	pub := NewPublisherWithOptions(prod, PublisherOptions{
+		Topics:      &TopicRewriter{Prefix: "staging."},
+		Serializers: Serializers{{Pattern: "orders", Serializer: NewJSONSchemaSerializer(NewSchemaRegistry(reg.URL, time.Minute))}},
+	})
+
+	msg := &outbox.Message{
+		Id:          1,
+		PayloadJson: []byte(`{"id":1}`),
+		Topic:       "orders",
+	}
+
+	if err := pub.PublishMessage(msg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := &sarama.ProducerMessage{
+		Topic:    "staging.orders",
+		Headers:  []sarama.RecordHeader{},
+		Value:    sarama.ByteEncoder(append([]byte{0, 0, 0, 0, 7}, `{"id":1}`...)),
+		Metadata: msg,
+	}
+
+	if err := prod.MessageWasProduced("staging.orders", exp); err != nil {
+		t.Error(err)
+	}
+
+	err := pub.PublishMessage(&outbox.Message{Id: 2, Topic: "orders", PayloadJson: []byte(`[]`)})
+	if class := outbox.ClassOf(err); err == nil || class != outbox.Permanent {
+		t.Errorf("expected a permanent error for the payload that does not match the schema, but got %v", err)
+	}
+}
+
+func TestPublisher_PublishMessageWithRelayHeaders(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	pub := NewPublisherWithOptions(prod, PublisherOptions{
+		Headers: &RelayHeaders{
+			OutboxId:     true,
+			Database:     true,
+			CreatedAt:    true,
+			BatchId:      true,
+			Attempt:      true,
+			MessageId:    true,
+			TraceContext: true,
+			DatabaseName: "orders",
+			OutboxTable:  "kafka_outbox",
+		},
+	})
+
+	batchId := uuid.MustParse("a1b2c3d4-e5f6-4a5b-8c7d-9e8f7a6b5c4d")
+	createdAt := time.Date(2022, 10, 1, 12, 30, 0, 500, time.UTC)
+	msg := &outbox.Message{
+		Id:             12,
+		BatchId:        &batchId,
+		PayloadJson:    []byte(`{"payload"}`),
+		PayloadHeaders: []byte(`{"x-outbox-attempt":"app"}`),
+		Topic:          "productUpdate",
+		PushAttempts:   2,
+		RetryAttempts:  3,
+		CreatedAt:      sql.NullTime{Time: createdAt, Valid: true},
+		TraceHeaders: map[string]string{
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"newrelic":    "eyJ2IjpbMCwxXX0=",
+		},
+	}
+
+	if err := pub.PublishMessage(msg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := &sarama.ProducerMessage{
+		Topic: "productUpdate",
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("x-outbox-id"), Value: []byte("12")},
+			{Key: []byte("x-outbox-database"), Value: []byte("orders")},
+			{Key: []byte("x-outbox-created-at"), Value: []byte("2022-10-01T12:30:00.0000005Z")},
+			{Key: []byte("x-outbox-batch-id"), Value: []byte("a1b2c3d4-e5f6-4a5b-8c7d-9e8f7a6b5c4d")},
+			{Key: []byte("x-outbox-attempt"), Value: []byte("6")},
+			{Key: []byte("x-outbox-message-id"), Value: []byte(pub.opts.Headers.messageId(msg))},
+			{Key: []byte("newrelic"), Value: []byte("eyJ2IjpbMCwxXX0=")},
+			{Key: []byte("traceparent"), Value: []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")},
+		},
+		Value:    sarama.ByteEncoder(`{"payload"}`),
+		Metadata: msg,
+	}
+
+	if err := prod.MessageWasProduced("productUpdate", exp); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPublisher_PublishMessageWithTombstone(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	pub := NewPublisherWithOptions(prod, PublisherOptions{
+		Serializers: Serializers{{Pattern: "*", Serializer: NewAvroSerializer(nil)}},
+	})
+
+	msg := &outbox.Message{
+		Id:          1,
+		PayloadJson: []byte(`null`),
+		Topic:       "productUpdate",
+		Key:         "SKU-123",
+		Tombstone:   true,
+	}
+
+	if err := pub.PublishMessage(msg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := &sarama.ProducerMessage{
+		Topic:    "productUpdate",
+		Headers:  []sarama.RecordHeader{},
+		Key:      newMessageKey("SKU-123", ""),
+		Value:    nil,
+		Metadata: msg,
+	}
+
+	if err := prod.MessageWasProduced("productUpdate", exp); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPublisher_PublishMessageWithTombstoneWithoutKey(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	pub := NewPublisherWithProducer(prod)
+
+	err := pub.PublishMessage(&outbox.Message{
+		Id:          1,
+		PayloadJson: []byte(`null`),
+		Topic:       "productUpdate",
+		Tombstone:   true,
+	})
+	if err == nil {
+		t.Fatal("expected an error but got nil")
+	}
+
+	if class := outbox.ClassOf(err); class != outbox.Permanent {
+		t.Errorf("expected a permanent error, but got %s", class)
 	}
 }
 
@@ -186,3 +457,110 @@ func TestPublisher_PublishMessageWithSendError(t *testing.T) {
 		t.Error("expected an error but got nil")
 	}
 }
+
+func TestPublisher_PublishBatch(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	pub := NewPublisherWithProducer(prod)
+
+	msgs := []*outbox.Message{
+		{
+			Id:             1,
+			PayloadJson:    []byte(`{"payload":1}`),
+			PayloadHeaders: []byte(`{"x-event-id":"id"}`),
+			Topic:          "productUpdate",
+			Key:            "bar",
+		},
+		{
+			Id:          2,
+			PayloadJson: []byte(`{"payload":2}`),
+			Topic:       "priceUpdate",
+		},
+	}
+
+	if err := pub.PublishBatch(msgs); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, m := range msgs {
+		if m.ErrorReason != nil {
+			t.Errorf("unexpected error reason on message %d: %s", m.Id, m.ErrorReason)
+		}
+	}
+
+	exp := &sarama.ProducerMessage{
+		Topic: "productUpdate",
+		Headers: []sarama.RecordHeader{
+			{
+				Key:   []byte("x-event-id"),
+				Value: []byte("id"),
+			},
+		},
+		Key:      newMessageKey("bar", ""),
+		Value:    sarama.ByteEncoder(`{"payload":1}`),
+		Metadata: msgs[0],
+	}
+
+	if err := prod.MessageWasProduced("productUpdate", exp); err != nil {
+		t.Error(err)
+	}
+
+	exp = &sarama.ProducerMessage{
+		Topic:    "priceUpdate",
+		Headers:  []sarama.RecordHeader{},
+		Value:    sarama.ByteEncoder(`{"payload":2}`),
+		Metadata: msgs[1],
+	}
+
+	if err := prod.MessageWasProduced("priceUpdate", exp); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPublisher_PublishBatchWithProducerErrors(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	prod.ErrorForMessageValue(`{"payload":2}`, sarama.ErrMessageSizeTooLarge)
+	pub := NewPublisherWithProducer(prod)
+
+	msgs := []*outbox.Message{
+		{Id: 1, PayloadJson: []byte(`{"payload":1}`), Topic: "productUpdate"},
+		{Id: 2, PayloadJson: []byte(`{"payload":2}`), Topic: "productUpdate"},
+		{Id: 3, PayloadJson: []byte(`{"payload":3}`), PayloadHeaders: []byte(`{"x-}`), Topic: "productUpdate"},
+	}
+
+	if err := pub.PublishBatch(msgs); err == nil {
+		t.Error("expected an error but got nil")
+	}
+
+	if msgs[0].ErrorReason != nil {
+		t.Errorf("unexpected error reason on the first message: %s", msgs[0].ErrorReason)
+	}
+
+	if class := outbox.ClassOf(msgs[1].ErrorReason); msgs[1].ErrorReason == nil || class != outbox.Permanent {
+		t.Errorf("expected the second message to be marked with a permanent error, but got %s", class)
+	}
+
+	if class := outbox.ClassOf(msgs[2].ErrorReason); msgs[2].ErrorReason == nil || class != outbox.Permanent {
+		t.Errorf("expected the message with invalid headers to be marked with a permanent error, but got %s", class)
+	}
+}
+
+func TestPublisher_PublishBatchWithSendError(t *testing.T) {
+	prod := test.NewMockSyncProducer()
+	prod.ErrorForSendMessages(sarama.ErrOutOfBrokers)
+	pub := NewPublisherWithProducer(prod)
+
+	msgs := []*outbox.Message{
+		{Id: 1, PayloadJson: []byte(`{"payload":1}`), Topic: "productUpdate"},
+		{Id: 2, PayloadJson: []byte(`{"payload":2}`), Topic: "productUpdate"},
+	}
+
+	if err := pub.PublishBatch(msgs); err == nil {
+		t.Error("expected an error but got nil")
+	}
+
+	for _, m := range msgs {
+		if class := outbox.ClassOf(m.ErrorReason); m.ErrorReason == nil || class != outbox.Retriable {
+			t.Errorf("expected message %d to be marked with a retriable error, but got %s", m.Id, class)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"inviqa/kafka-outbox-relay/outbox"
+
+	"github.com/Shopify/sarama"
+	"github.com/google/uuid"
+)
+
+// The headers that the relay can add to each message.
+const (
+	HeaderOutboxId  = "x-outbox-id"
+	HeaderDatabase  = "x-outbox-database"
+	HeaderCreatedAt = "x-outbox-created-at"
+	HeaderBatchId   = "x-outbox-batch-id"
+	HeaderAttempt   = "x-outbox-attempt"
+	HeaderMessageId = "x-outbox-message-id"
+)
+
+// messageIdNamespace is the namespace of the name based UUIDs generated for
+// each message's HeaderMessageId.
+var messageIdNamespace = uuid.MustParse("0b0a3ff6-3b1c-4d5e-9c38-6a1f2f1d7e42")
+
+// RelayHeaders holds the headers that the relay adds to each message, which
+// describe where the message came from, and allow consumers to deduplicate any
+// messages that are published more than once, e.g. after a stale batch is
+// reclaimed by another relay.
+type RelayHeaders struct {
+	OutboxId  bool
+	Database  bool
+	CreatedAt bool
+	BatchId   bool
+	Attempt   bool
+	MessageId bool
+	// TraceContext adds the distributed trace headers of the transaction that
+	// published the message, e.g. traceparent, so that consumers can continue
+	// the trace.
+	TraceContext bool
+	// DatabaseName and OutboxTable identify the outbox that the messages are
+	// relayed from.
+	DatabaseName string
+	OutboxTable  string
+}
+
+// add adds the enabled headers for the message to headers, replacing any header
+// with the same key from the message's payload headers.
+func (h *RelayHeaders) add(headers []sarama.RecordHeader, m *outbox.Message) []sarama.RecordHeader {
+	if h == nil {
+		return headers
+	}
+
+	relay := map[string]string{}
+	if h.OutboxId {
+		relay[HeaderOutboxId] = strconv.FormatUint(uint64(m.Id), 10)
+	}
+	if h.Database {
+		relay[HeaderDatabase] = h.DatabaseName
+	}
+	if h.CreatedAt && m.CreatedAt.Valid {
+		relay[HeaderCreatedAt] = m.CreatedAt.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if h.BatchId && m.BatchId != nil {
+		relay[HeaderBatchId] = m.BatchId.String()
+	}
+	if h.Attempt {
+		// retries whilst Kafka is unavailable do not use up the publish
+		// attempts, but they are still attempts to publish the message
+		relay[HeaderAttempt] = strconv.Itoa(m.PushAttempts + m.RetryAttempts + 1)
+	}
+	if h.MessageId {
+		relay[HeaderMessageId] = h.messageId(m)
+	}
+
+	keys := []string{HeaderOutboxId, HeaderDatabase, HeaderCreatedAt, HeaderBatchId, HeaderAttempt, HeaderMessageId}
+	if h.TraceContext {
+		traceKeys := make([]string, 0, len(m.TraceHeaders))
+		for key, val := range m.TraceHeaders {
+			relay[key] = val
+			traceKeys = append(traceKeys, key)
+		}
+		sort.Strings(traceKeys)
+		keys = append(keys, traceKeys...)
+	}
+
+	if len(relay) == 0 {
+		return headers
+	}
+
+	enriched := make([]sarama.RecordHeader, 0, len(headers)+len(relay))
+	for _, rec := range headers {
+		if _, ok := relay[string(rec.Key)]; !ok {
+			enriched = append(enriched, rec)
+		}
+	}
+	for _, key := range keys {
+		if val, ok := relay[key]; ok {
+			enriched = append(enriched, sarama.RecordHeader{Key: []byte(key), Value: []byte(val)})
+		}
+	}
+
+	return enriched
+}
+
+// messageId returns a UUID that identifies the message, which is the same each
+// time the message is published, as it is generated from the outbox that the
+// message is in, its ID, and when it was created.
+func (h *RelayHeaders) messageId(m *outbox.Message) string {
+	var createdAt int64
+	if m.CreatedAt.Valid {
+		createdAt = m.CreatedAt.Time.UnixNano()
+	}
+
+	name := fmt.Sprintf("%s/%s/%d/%d", h.DatabaseName, h.OutboxTable, m.Id, createdAt)
+
+	return uuid.NewSHA1(messageIdNamespace, []byte(name)).String()
+}
@@ -0,0 +1,43 @@
+package kafka
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"inviqa/kafka-outbox-relay/outbox"
+)
+
+func TestRelayHeaders_MessageId(t *testing.T) {
+	h := &RelayHeaders{DatabaseName: "orders", OutboxTable: "kafka_outbox"}
+	createdAt := sql.NullTime{Time: time.Date(2022, 10, 1, 12, 30, 0, 0, time.UTC), Valid: true}
+	msg := &outbox.Message{Id: 12, PushAttempts: 1, CreatedAt: createdAt}
+
+	id := h.messageId(msg)
+	if id != h.messageId(&outbox.Message{Id: 12, PushAttempts: 4, CreatedAt: createdAt}) {
+		t.Error("expected the message ID to be the same each time the message is published")
+	}
+
+	others := map[string]string{
+		"another row":      h.messageId(&outbox.Message{Id: 13, CreatedAt: createdAt}),
+		"another database": (&RelayHeaders{DatabaseName: "customers", OutboxTable: "kafka_outbox"}).messageId(msg),
+		"another table":    (&RelayHeaders{DatabaseName: "orders", OutboxTable: "events"}).messageId(msg),
+	}
+	for name, other := range others {
+		if other == id {
+			t.Errorf("expected the message ID for %s to be different, but got %s", name, id)
+		}
+	}
+}
+
+func TestRelayHeaders_AddWithoutHeaders(t *testing.T) {
+	var h *RelayHeaders
+	if got := h.add(nil, &outbox.Message{Id: 1}); got != nil {
+		t.Errorf("expected no headers to be added, but got %v", got)
+	}
+
+	h = &RelayHeaders{BatchId: true, CreatedAt: true, TraceContext: true}
+	if got := h.add(nil, &outbox.Message{Id: 1}); got != nil {
+		t.Errorf("expected no headers to be added for a message without a batch or creation time, but got %v", got)
+	}
+}
@@ -0,0 +1,163 @@
+package kafka
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+)
+
+// ReloadableProducer is a sarama.SyncProducer that can be replaced with a new
+// producer, e.g. after the Kafka credentials have been rotated, without
+// interrupting any messages or transaction in flight. Reload waits until the
+// current producer is no longer in use, including until any transaction that
+// has begun is committed or aborted, before replacing it.
+type ReloadableProducer struct {
+	mu          sync.RWMutex
+	producer    sarama.SyncProducer
+	newProducer func() (sarama.SyncProducer, error)
+	// inTxn is set whilst a transaction holds the read lock, so that the
+	// producer can be used until the transaction ends without locking again
+	inTxn int32
+}
+
+// NewReloadableProducer creates a producer using newProducer, which is called
+// again to create its replacement each time the producer is reloaded.
+func NewReloadableProducer(newProducer func() (sarama.SyncProducer, error)) (*ReloadableProducer, error) {
+	prod, err := newProducer()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReloadableProducer{
+		producer:    prod,
+		newProducer: newProducer,
+	}, nil
+}
+
+// Reload replaces the current producer with a new one, and closes the current
+// producer. If the new producer cannot be created, then the current producer
+// is kept. The new producer is only created once the current one is no longer
+// in use, as a new transactional producer fences any other producer with the
+// same transactional ID.
+func (p *ReloadableProducer) Reload() error {
+	p.mu.Lock()
+	prod, err := p.newProducer()
+	if err != nil {
+		p.mu.Unlock()
+		return fmt.Errorf("unable to reload the Kafka producer: %w", err)
+	}
+	old := p.producer
+	p.producer = prod
+	p.mu.Unlock()
+
+	return old.Close()
+}
+
+// use calls fn with the current producer, making sure it is not replaced until
+// fn returns.
+func (p *ReloadableProducer) use(fn func(prod sarama.SyncProducer)) {
+	if atomic.LoadInt32(&p.inTxn) == 0 {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+	}
+
+	fn(p.producer)
+}
+
+func (p *ReloadableProducer) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	p.use(func(prod sarama.SyncProducer) {
+		partition, offset, err = prod.SendMessage(msg)
+	})
+
+	return partition, offset, err
+}
+
+func (p *ReloadableProducer) SendMessages(msgs []*sarama.ProducerMessage) (err error) {
+	p.use(func(prod sarama.SyncProducer) {
+		err = prod.SendMessages(msgs)
+	})
+
+	return err
+}
+
+func (p *ReloadableProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.producer.Close()
+}
+
+func (p *ReloadableProducer) TxnStatus() (status sarama.ProducerTxnStatusFlag) {
+	p.use(func(prod sarama.SyncProducer) {
+		status = prod.TxnStatus()
+	})
+
+	return status
+}
+
+func (p *ReloadableProducer) IsTransactional() (transactional bool) {
+	p.use(func(prod sarama.SyncProducer) {
+		transactional = prod.IsTransactional()
+	})
+
+	return transactional
+}
+
+// BeginTxn begins a transaction, holding on to the current producer until the
+// transaction is committed or aborted.
+func (p *ReloadableProducer) BeginTxn() error {
+	p.mu.RLock()
+	if err := p.producer.BeginTxn(); err != nil {
+		p.mu.RUnlock()
+		return err
+	}
+	atomic.StoreInt32(&p.inTxn, 1)
+
+	return nil
+}
+
+// CommitTxn commits the transaction. If the commit fails, then the producer is
+// held on to until the transaction is aborted.
+func (p *ReloadableProducer) CommitTxn() (err error) {
+	p.use(func(prod sarama.SyncProducer) {
+		err = prod.CommitTxn()
+	})
+	if err == nil {
+		p.endTxn()
+	}
+
+	return err
+}
+
+func (p *ReloadableProducer) AbortTxn() (err error) {
+	p.use(func(prod sarama.SyncProducer) {
+		err = prod.AbortTxn()
+	})
+	p.endTxn()
+
+	return err
+}
+
+func (p *ReloadableProducer) endTxn() {
+	if atomic.CompareAndSwapInt32(&p.inTxn, 1, 0) {
+		p.mu.RUnlock()
+	}
+}
+
+func (p *ReloadableProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupId string) (err error) {
+	p.use(func(prod sarama.SyncProducer) {
+		err = prod.AddOffsetsToTxn(offsets, groupId)
+	})
+
+	return err
+}
+
+func (p *ReloadableProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupId string, metadata *string) (err error) {
+	p.use(func(prod sarama.SyncProducer) {
+		err = prod.AddMessageToTxn(msg, groupId, metadata)
+	})
+
+	return err
+}
@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"inviqa/kafka-outbox-relay/kafka/test"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestReloadableProducer_Reload(t *testing.T) {
+	first, second := test.NewMockSyncProducer(), test.NewMockSyncProducer()
+	producers := []sarama.SyncProducer{first, second}
+	var factoryErr error
+	prod, err := NewReloadableProducer(func() (sarama.SyncProducer, error) {
+		if factoryErr != nil {
+			return nil, factoryErr
+		}
+		p := producers[0]
+		producers = producers[1:]
+		return p, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	before := &sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("before")}
+	after := &sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("after")}
+
+	if _, _, err := prod.SendMessage(before); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := prod.Reload(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	factoryErr = errors.New("oops")
+	if err := prod.Reload(); err == nil {
+		t.Error("expected an error when the new producer cannot be created, but got nil")
+	}
+
+	if err := prod.SendMessages([]*sarama.ProducerMessage{after}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := first.MessageWasProduced("test", before); err != nil {
+		t.Errorf("expected the first message to be produced by the original producer: %s", err)
+	}
+
+	if err := second.MessageWasProduced("test", after); err != nil {
+		t.Errorf("expected the second message to be produced by the reloaded producer: %s", err)
+	}
+}
+
+func TestReloadableProducer_ReloadWaitsForTransaction(t *testing.T) {
+	prod, err := NewReloadableProducer(func() (sarama.SyncProducer, error) {
+		return test.NewMockSyncProducer(), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := prod.BeginTxn(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	reloaded := make(chan error)
+	go func() {
+		reloaded <- prod.Reload()
+	}()
+
+	if err := prod.SendMessages([]*sarama.ProducerMessage{{Topic: "test", Value: sarama.StringEncoder("foo")}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	select {
+	case <-reloaded:
+		t.Fatal("the producer was reloaded during a transaction")
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	if err := prod.CommitTxn(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the producer was not reloaded after the transaction was committed")
+	}
+}
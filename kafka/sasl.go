@@ -0,0 +1,86 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg-go/scram"
+)
+
+// SASL holds the settings used to authenticate with Kafka. User and Password
+// are used by the PLAIN and SCRAM mechanisms, whereas Token is only used by the
+// OAUTHBEARER mechanism, and is called each time a broker connection is
+// authenticated, so that the token can be refreshed without restarting the
+// relay.
+type SASL struct {
+	Mechanism sarama.SASLMechanism
+	User      string
+	Password  string
+	Token     func() (string, error)
+}
+
+// EnableSASL configures cfg to authenticate with Kafka using SASL.
+func EnableSASL(cfg *sarama.Config, sasl SASL) {
+	cfg.Net.SASL.Enable = true
+	cfg.Net.SASL.Handshake = true
+	cfg.Net.SASL.Mechanism = sasl.Mechanism
+
+	switch sasl.Mechanism {
+	case sarama.SASLTypeOAuth:
+		cfg.Net.SASL.TokenProvider = tokenProvider(sasl.Token)
+	case sarama.SASLTypeSCRAMSHA256:
+		cfg.Net.SASL.User = sasl.User
+		cfg.Net.SASL.Password = sasl.Password
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGen: scram.SHA256}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		cfg.Net.SASL.User = sasl.User
+		cfg.Net.SASL.Password = sasl.Password
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGen: scram.SHA512}
+		}
+	default:
+		cfg.Net.SASL.User = sasl.User
+		cfg.Net.SASL.Password = sasl.Password
+	}
+}
+
+// scramClient implements sarama.SCRAMClient, which Sarama uses to carry out
+// each SCRAM authentication exchange with a broker.
+type scramClient struct {
+	hashGen      scram.HashGeneratorFcn
+	conversation *scram.ClientConversation
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.hashGen.NewClient(userName, password, authzID)
+	if err != nil {
+		return fmt.Errorf("unable to create the SCRAM client: %w", err)
+	}
+
+	c.conversation = client.NewConversation()
+
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
+
+// tokenProvider implements sarama.AccessTokenProvider, returning the token
+// given by the func each time a broker connection is authenticated.
+type tokenProvider func() (string, error)
+
+func (p tokenProvider) Token() (*sarama.AccessToken, error) {
+	token, err := p()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sarama.AccessToken{Token: token}, nil
+}
@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestEnableSASL(t *testing.T) {
+	tests := []struct {
+		name      string
+		sasl      SASL
+		wantScram bool
+	}{
+		{
+			name: "PLAIN",
+			sasl: SASL{Mechanism: sarama.SASLTypePlaintext, User: "relay", Password: "pass"},
+		},
+		{
+			name:      "SCRAM-SHA-256",
+			sasl:      SASL{Mechanism: sarama.SASLTypeSCRAMSHA256, User: "relay", Password: "pass"},
+			wantScram: true,
+		},
+		{
+			name:      "SCRAM-SHA-512",
+			sasl:      SASL{Mechanism: sarama.SASLTypeSCRAMSHA512, User: "relay", Password: "pass"},
+			wantScram: true,
+		},
+		{
+			name: "OAUTHBEARER",
+			sasl: SASL{Mechanism: sarama.SASLTypeOAuth, Token: func() (string, error) { return "token", nil }},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewSaramaConfig(true, false)
+			EnableSASL(cfg, tt.sasl)
+
+			if !cfg.Net.SASL.Enable || cfg.Net.SASL.Mechanism != tt.sasl.Mechanism {
+				t.Errorf("expected SASL to be enabled with the %s mechanism, but got %s", tt.sasl.Mechanism, cfg.Net.SASL.Mechanism)
+			}
+
+			if cfg.Net.SASL.User != tt.sasl.User || cfg.Net.SASL.Password != tt.sasl.Password {
+				t.Errorf("expected the SASL user and password to be '%s' and '%s', but got '%s' and '%s'", tt.sasl.User, tt.sasl.Password, cfg.Net.SASL.User, cfg.Net.SASL.Password)
+			}
+
+			if tt.wantScram && cfg.Net.SASL.SCRAMClientGeneratorFunc == nil {
+				t.Error("expected a SCRAM client to be configured")
+			}
+
+			if err := cfg.Validate(); err != nil {
+				t.Errorf("expected a valid SASL config, but got error: %s", err)
+			}
+		})
+	}
+}
+
+func TestScramClient(t *testing.T) {
+	cfg := NewSaramaConfig(false, false)
+	EnableSASL(cfg, SASL{Mechanism: sarama.SASLTypeSCRAMSHA512, User: "relay", Password: "pass"})
+
+	cl := cfg.Net.SASL.SCRAMClientGeneratorFunc()
+	if err := cl.Begin("relay", "pass", ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	first, err := cl.Step("")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !strings.HasPrefix(first, "n,,n=relay,r=") {
+		t.Errorf("expected the client-first message for the relay user, but got '%s'", first)
+	}
+
+	if cl.Done() {
+		t.Error("expected the SCRAM conversation not to be done")
+	}
+}
@@ -0,0 +1,135 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"inviqa/kafka-outbox-relay/outbox"
+)
+
+// The schema types returned by the schema registry. An empty schema type is an
+// Avro schema.
+const (
+	SchemaTypeAvro       = "AVRO"
+	SchemaTypeProtobuf   = "PROTOBUF"
+	SchemaTypeJSONSchema = "JSON"
+)
+
+// Schema is a schema registered in the schema registry.
+type Schema struct {
+	Id         int    `json:"id"`
+	Subject    string `json:"subject"`
+	Version    int    `json:"version"`
+	SchemaType string `json:"schemaType"`
+	Schema     string `json:"schema"`
+}
+
+// SchemaRegistry fetches the latest schema for each subject from a Confluent
+// compatible schema registry, caching each schema for cacheTTL so that the
+// registry is not called for every message, whilst still picking up any new
+// versions of the schemas.
+type SchemaRegistry struct {
+	url      string
+	user     string
+	password string
+	cacheTTL time.Duration
+	client   *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedSchema
+}
+
+type cachedSchema struct {
+	schema  Schema
+	expires time.Time
+}
+
+func NewSchemaRegistry(registryUrl string, cacheTTL time.Duration) *SchemaRegistry {
+	return NewSchemaRegistryWithBasicAuth(registryUrl, "", "", cacheTTL)
+}
+
+// NewSchemaRegistryWithBasicAuth creates a SchemaRegistry that authenticates
+// with the registry using HTTP basic authentication.
+func NewSchemaRegistryWithBasicAuth(registryUrl, user, password string, cacheTTL time.Duration) *SchemaRegistry {
+	return &SchemaRegistry{
+		url:      strings.TrimRight(registryUrl, "/"),
+		user:     user,
+		password: password,
+		cacheTTL: cacheTTL,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		cache:    map[string]cachedSchema{},
+	}
+}
+
+// LatestSchema returns the latest version of the schema registered for the
+// subject. Any error caused by the registry being unavailable is retriable, so
+// that the messages are published once it is available again, whereas a
+// subject that does not exist is a permanent error.
+func (r *SchemaRegistry) LatestSchema(subject string) (Schema, error) {
+	r.mu.Lock()
+	c, ok := r.cache[subject]
+	r.mu.Unlock()
+
+	if ok && time.Now().Before(c.expires) {
+		return c.schema, nil
+	}
+
+	// the schema is fetched without holding the lock, so that a slow registry
+	// does not block the serializers for every other subject
+	schema, err := r.fetchLatestSchema(subject)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	r.mu.Lock()
+	r.cache[subject] = cachedSchema{schema: schema, expires: time.Now().Add(r.cacheTTL)}
+	r.mu.Unlock()
+
+	return schema, nil
+}
+
+func (r *SchemaRegistry) fetchLatestSchema(subject string) (Schema, error) {
+	req, err := http.NewRequest(http.MethodGet, r.url+"/subjects/"+url.PathEscape(subject)+"/versions/latest", nil)
+	if err != nil {
+		return Schema{}, fmt.Errorf("unable to create the schema registry request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if r.user != "" {
+		req.SetBasicAuth(r.user, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return Schema{}, outbox.NewRetriableError(fmt.Errorf("unable to fetch the schema for the subject %s: %w", subject, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("the schema registry responded with %s for the subject %s: %s", resp.Status, subject, body)
+		switch {
+		case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+			return Schema{}, outbox.NewRetriableError(err)
+		case resp.StatusCode == http.StatusNotFound:
+			return Schema{}, outbox.NewPermanentError(err)
+		}
+		return Schema{}, err
+	}
+
+	var schema Schema
+	if err := json.NewDecoder(resp.Body).Decode(&schema); err != nil {
+		return Schema{}, fmt.Errorf("unable to decode the schema for the subject %s: %w", subject, err)
+	}
+
+	if schema.SchemaType == "" {
+		schema.SchemaType = SchemaTypeAvro
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,89 @@
+package kafka
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"inviqa/kafka-outbox-relay/kafka/test"
+	"inviqa/kafka-outbox-relay/outbox"
+)
+
+func TestSchemaRegistry_LatestSchema(t *testing.T) {
+	reg := test.NewMockSchemaRegistry()
+	defer reg.Close()
+	reg.RegisterSchema("orders-value", 7, "", `"string"`)
+
+	r := NewSchemaRegistry(reg.URL, time.Minute)
+	for i := 0; i < 2; i++ {
+		schema, err := r.LatestSchema("orders-value")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if schema.Id != 7 || schema.SchemaType != SchemaTypeAvro || schema.Schema != `"string"` {
+			t.Errorf("unexpected schema: %+v", schema)
+		}
+	}
+
+	if reg.Requests() != 1 {
+		t.Errorf("expected the schema to be fetched once and then cached, but the registry served %d requests", reg.Requests())
+	}
+}
+
+func TestSchemaRegistry_LatestSchemaFetchesNewVersionsAfterCacheTTL(t *testing.T) {
+	reg := test.NewMockSchemaRegistry()
+	defer reg.Close()
+	reg.RegisterSchema("orders-value", 7, SchemaTypeAvro, `"string"`)
+
+	r := NewSchemaRegistry(reg.URL, 0)
+	if _, err := r.LatestSchema("orders-value"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	reg.RegisterSchema("orders-value", 8, SchemaTypeAvro, `"bytes"`)
+	schema, err := r.LatestSchema("orders-value")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if schema.Id != 8 || schema.Version != 2 {
+		t.Errorf("expected the new version of the schema, but got %+v", schema)
+	}
+}
+
+func TestSchemaRegistry_LatestSchemaWithErrors(t *testing.T) {
+	reg := test.NewMockSchemaRegistry()
+	defer reg.Close()
+
+	_, err := NewSchemaRegistry(reg.URL, time.Minute).LatestSchema("missing-value")
+	if class := outbox.ClassOf(err); err == nil || class != outbox.Permanent {
+		t.Errorf("expected a permanent error for the missing subject, but got %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err = NewSchemaRegistry(srv.URL, time.Minute).LatestSchema("orders-value")
+	if class := outbox.ClassOf(err); err == nil || class != outbox.Retriable {
+		t.Errorf("expected a retriable error when the registry is unavailable, but got %v", err)
+	}
+}
+
+func TestSchemaRegistry_LatestSchemaWithBasicAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "relay" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":1,"schema":"\"string\""}`))
+	}))
+	defer srv.Close()
+
+	if _, err := NewSchemaRegistryWithBasicAuth(srv.URL, "relay", "secret", time.Minute).LatestSchema("orders-value"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,223 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"inviqa/kafka-outbox-relay/outbox"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/linkedin/goavro/v2"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Serializer converts the JSON payload of a message into the bytes that are
+// published to the topic.
+type Serializer interface {
+	Serialize(topic string, payload []byte) ([]byte, error)
+}
+
+// TopicSerializer serializes the payload of the messages whose logical topic
+// matches Pattern, e.g. "orders.*".
+type TopicSerializer struct {
+	Pattern    string
+	Serializer Serializer
+}
+
+// Serializers chooses the Serializer for each logical topic, which is the
+// Serializer of the first TopicSerializer whose Pattern matches the topic.
+type Serializers []TopicSerializer
+
+// For returns the Serializer for the logical topic, or nil if the payloads for
+// the topic are published as they are.
+func (s Serializers) For(topic string) Serializer {
+	for _, ts := range s {
+		if ok, _ := path.Match(ts.Pattern, topic); ok {
+			return ts.Serializer
+		}
+	}
+
+	return nil
+}
+
+// encoder converts a JSON payload into the format of the schema that it was
+// created from, returning an error if the payload does not match the schema.
+type encoder func(payload []byte) ([]byte, error)
+
+// registrySerializer serializes payloads using the latest schema registered
+// for each topic's value in a schema registry, i.e. the <topic>-value subject,
+// framing them in the Confluent wire format so that consumers can look up the
+// schema that each message was written with.
+type registrySerializer struct {
+	registry   *SchemaRegistry
+	schemaType string
+	newEncoder func(schema Schema) (encoder, error)
+
+	mu       sync.Mutex
+	encoders map[int]encoder
+}
+
+// NewAvroSerializer creates a Serializer that converts payloads into Avro using
+// the schemas in the registry. The payloads must be in the Avro JSON encoding,
+// in which the value of a union is wrapped in an object naming its type, e.g.
+// {"string": "foo"}, unless it is null.
+func NewAvroSerializer(registry *SchemaRegistry) Serializer {
+	return newRegistrySerializer(registry, SchemaTypeAvro, newAvroEncoder)
+}
+
+// NewProtobufSerializer creates a Serializer that converts payloads into
+// Protobuf using the schemas in the registry. Each payload is converted into
+// the first message type defined in the schema.
+func NewProtobufSerializer(registry *SchemaRegistry) Serializer {
+	return newRegistrySerializer(registry, SchemaTypeProtobuf, newProtobufEncoder)
+}
+
+// NewJSONSchemaSerializer creates a Serializer that validates payloads against
+// the JSON schemas in the registry, publishing them as JSON.
+func NewJSONSchemaSerializer(registry *SchemaRegistry) Serializer {
+	return newRegistrySerializer(registry, SchemaTypeJSONSchema, newJSONSchemaEncoder)
+}
+
+func newRegistrySerializer(registry *SchemaRegistry, schemaType string, newEncoder func(schema Schema) (encoder, error)) *registrySerializer {
+	return &registrySerializer{
+		registry:   registry,
+		schemaType: schemaType,
+		newEncoder: newEncoder,
+		encoders:   map[int]encoder{},
+	}
+}
+
+// Serialize converts the payload using the latest schema for the topic. A
+// permanent error is returned if the schema is of the wrong type or cannot be
+// parsed, or if the payload does not match the schema, as publishing the
+// message again will not change the outcome.
+func (s *registrySerializer) Serialize(topic string, payload []byte) ([]byte, error) {
+	schema, err := s.registry.LatestSchema(topic + "-value")
+	if err != nil {
+		return nil, err
+	}
+
+	if schema.SchemaType != s.schemaType {
+		return nil, outbox.NewPermanentError(fmt.Errorf("the schema for the topic %s is a %s schema, but a %s schema was expected", topic, schema.SchemaType, s.schemaType))
+	}
+
+	enc, err := s.encoder(schema)
+	if err != nil {
+		return nil, outbox.NewPermanentError(fmt.Errorf("unable to parse the %s schema %d for the topic %s: %w", s.schemaType, schema.Id, topic, err))
+	}
+
+	body, err := enc(payload)
+	if err != nil {
+		return nil, outbox.NewPermanentError(fmt.Errorf("the payload does not match the %s schema %d for the topic %s: %w", s.schemaType, schema.Id, topic, err))
+	}
+
+	// the Confluent wire format is a zero magic byte, followed by the schema
+	// ID as a big-endian 32 bit integer, followed by the serialized payload
+	framed := make([]byte, 5, 5+len(body))
+	binary.BigEndian.PutUint32(framed[1:], uint32(schema.Id))
+
+	return append(framed, body...), nil
+}
+
+// encoder returns the encoder for the schema, parsing the schema only the
+// first time that it is used.
+func (s *registrySerializer) encoder(schema Schema) (encoder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if enc, ok := s.encoders[schema.Id]; ok {
+		return enc, nil
+	}
+
+	enc, err := s.newEncoder(schema)
+	if err != nil {
+		return nil, err
+	}
+	s.encoders[schema.Id] = enc
+
+	return enc, nil
+}
+
+func newAvroEncoder(schema Schema) (encoder, error) {
+	codec, err := goavro.NewCodec(schema.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(payload []byte) ([]byte, error) {
+		native, rest, err := codec.NativeFromTextual(payload)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(rest)) > 0 {
+			return nil, fmt.Errorf("unexpected data after the payload: %s", rest)
+		}
+
+		return codec.BinaryFromNative(nil, native)
+	}, nil
+}
+
+func newProtobufEncoder(schema Schema) (encoder, error) {
+	parser := protoparse.Parser{
+		Accessor: protoparse.FileContentsFromMap(map[string]string{"schema.proto": schema.Schema}),
+	}
+	files, err := parser.ParseFiles("schema.proto")
+	if err != nil {
+		return nil, err
+	}
+
+	msgTypes := files[0].GetMessageTypes()
+	if len(msgTypes) == 0 {
+		return nil, fmt.Errorf("the schema does not define any messages")
+	}
+	md := msgTypes[0]
+
+	return func(payload []byte) ([]byte, error) {
+		msg := dynamic.NewMessage(md)
+		if err := msg.UnmarshalJSON(payload); err != nil {
+			return nil, err
+		}
+
+		b, err := msg.Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		// the message indexes identify the message type within the schema,
+		// where a single zero byte is the first message type
+		return append([]byte{0}, b...), nil
+	}, nil
+}
+
+func newJSONSchemaEncoder(schema Schema) (encoder, error) {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("schema.json", strings.NewReader(schema.Schema)); err != nil {
+		return nil, err
+	}
+
+	compiled, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, err
+	}
+
+	return func(payload []byte) ([]byte, error) {
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(payload))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+
+		if err := compiled.Validate(v); err != nil {
+			return nil, err
+		}
+
+		return payload, nil
+	}, nil
+}
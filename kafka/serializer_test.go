@@ -0,0 +1,181 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"inviqa/kafka-outbox-relay/kafka/test"
+	"inviqa/kafka-outbox-relay/outbox"
+
+	"github.com/go-test/deep"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/linkedin/goavro/v2"
+)
+
+const (
+	avroOrderSchema = `{
+		"type": "record",
+		"name": "Order",
+		"fields": [
+			{"name": "id", "type": "long"},
+			{"name": "note", "type": ["null", "string"], "default": null}
+		]
+	}`
+	protobufOrderSchema = `syntax = "proto3";
+		message Order {
+			int64 id = 1;
+			string note = 2;
+		}`
+	jsonOrderSchema = `{
+		"type": "object",
+		"properties": {"id": {"type": "integer"}},
+		"required": ["id"]
+	}`
+)
+
+func TestSerializers_For(t *testing.T) {
+	avro := NewAvroSerializer(nil)
+	s := Serializers{{Pattern: "orders.*", Serializer: avro}}
+
+	if s.For("orders.created") != avro {
+		t.Error("expected the Avro serializer for the orders.created topic")
+	}
+
+	if s.For("customers") != nil {
+		t.Error("expected no serializer for the customers topic")
+	}
+}
+
+func TestAvroSerializer_Serialize(t *testing.T) {
+	reg := test.NewMockSchemaRegistry()
+	defer reg.Close()
+	reg.RegisterSchema("orders-value", 42, SchemaTypeAvro, avroOrderSchema)
+
+	ser := NewAvroSerializer(NewSchemaRegistry(reg.URL, time.Minute))
+	b, err := ser.Serialize("orders", []byte(`{"id": 1, "note": {"string": "fragile"}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	assertWireFormat(t, b, 42)
+
+	codec, err := goavro.NewCodec(avroOrderSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	native, _, err := codec.NativeFromBinary(b[5:])
+	if err != nil {
+		t.Fatalf("unable to decode the Avro payload: %s", err)
+	}
+
+	exp := map[string]any{"id": int64(1), "note": map[string]any{"string": "fragile"}}
+	if diff := deep.Equal(exp, native); diff != nil {
+		t.Error(diff)
+	}
+
+	_, err = ser.Serialize("orders", []byte(`{"id": "one"}`))
+	if class := outbox.ClassOf(err); err == nil || class != outbox.Permanent {
+		t.Errorf("expected a permanent error for the payload that does not match the schema, but got %v", err)
+	}
+}
+
+func TestProtobufSerializer_Serialize(t *testing.T) {
+	reg := test.NewMockSchemaRegistry()
+	defer reg.Close()
+	reg.RegisterSchema("orders-value", 43, SchemaTypeProtobuf, protobufOrderSchema)
+
+	ser := NewProtobufSerializer(NewSchemaRegistry(reg.URL, time.Minute))
+	b, err := ser.Serialize("orders", []byte(`{"id": "1", "note": "fragile"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	assertWireFormat(t, b, 43)
+
+	if b[5] != 0 {
+		t.Errorf("expected the message indexes to refer to the first message type, but got %d", b[5])
+	}
+
+	files, err := (&protoparse.Parser{
+		Accessor: protoparse.FileContentsFromMap(map[string]string{"order.proto": protobufOrderSchema}),
+	}).ParseFiles("order.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := dynamic.NewMessage(files[0].GetMessageTypes()[0])
+	if err := msg.Unmarshal(b[6:]); err != nil {
+		t.Fatalf("unable to decode the Protobuf payload: %s", err)
+	}
+
+	if id, note := msg.GetFieldByName("id"), msg.GetFieldByName("note"); id != int64(1) || note != "fragile" {
+		t.Errorf("unexpected Protobuf message: %s", msg)
+	}
+
+	_, err = ser.Serialize("orders", []byte(`{"identifier": 1}`))
+	if class := outbox.ClassOf(err); err == nil || class != outbox.Permanent {
+		t.Errorf("expected a permanent error for the payload that does not match the schema, but got %v", err)
+	}
+}
+
+func TestJSONSchemaSerializer_Serialize(t *testing.T) {
+	reg := test.NewMockSchemaRegistry()
+	defer reg.Close()
+	reg.RegisterSchema("orders-value", 44, SchemaTypeJSONSchema, jsonOrderSchema)
+
+	ser := NewJSONSchemaSerializer(NewSchemaRegistry(reg.URL, time.Minute))
+	payload := []byte(`{"id": 1}`)
+	b, err := ser.Serialize("orders", payload)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	assertWireFormat(t, b, 44)
+
+	if !bytes.Equal(payload, b[5:]) {
+		t.Errorf("expected the payload to be published as it is, but got %s", b[5:])
+	}
+
+	_, err = ser.Serialize("orders", []byte(`{"id": 1.5}`))
+	if class := outbox.ClassOf(err); err == nil || class != outbox.Permanent {
+		t.Errorf("expected a permanent error for the payload that does not match the schema, but got %v", err)
+	}
+}
+
+func TestRegistrySerializer_SerializeWithWrongSchemaType(t *testing.T) {
+	reg := test.NewMockSchemaRegistry()
+	defer reg.Close()
+	reg.RegisterSchema("orders-value", 44, SchemaTypeJSONSchema, jsonOrderSchema)
+
+	_, err := NewAvroSerializer(NewSchemaRegistry(reg.URL, time.Minute)).Serialize("orders", []byte(`{"id": 1}`))
+	if class := outbox.ClassOf(err); err == nil || class != outbox.Permanent {
+		t.Errorf("expected a permanent error for the JSON schema, but got %v", err)
+	}
+}
+
+func TestRegistrySerializer_SerializeWithInvalidSchema(t *testing.T) {
+	reg := test.NewMockSchemaRegistry()
+	defer reg.Close()
+	reg.RegisterSchema("orders-value", 45, SchemaTypeAvro, `{"type": "record"}`)
+
+	_, err := NewAvroSerializer(NewSchemaRegistry(reg.URL, time.Minute)).Serialize("orders", []byte(`{"id": 1}`))
+	if class := outbox.ClassOf(err); err == nil || class != outbox.Permanent {
+		t.Errorf("expected a permanent error for the schema that cannot be parsed, but got %v", err)
+	}
+}
+
+func assertWireFormat(t *testing.T, b []byte, schemaId uint32) {
+	t.Helper()
+
+	if len(b) < 5 || b[0] != 0 {
+		t.Fatalf("expected the payload to start with the magic byte, but got %v", b)
+	}
+
+	if id := binary.BigEndian.Uint32(b[1:5]); id != schemaId {
+		t.Errorf("expected the schema ID %d, but got %d", schemaId, id)
+	}
+}
@@ -9,14 +9,29 @@ import (
 
 type mockSyncProducer struct {
 	producedMessages map[string][]*sarama.ProducerMessage
+	errors           map[string]error
+	sendMessagesErr  error
 }
 
 func NewMockSyncProducer() *mockSyncProducer {
 	return &mockSyncProducer{
 		producedMessages: map[string][]*sarama.ProducerMessage{},
+		errors:           map[string]error{},
 	}
 }
 
+// ErrorForMessageValue makes the producer fail to produce any message with
+// the given value, reporting it as a sarama.ProducerError from SendMessages.
+func (m *mockSyncProducer) ErrorForMessageValue(value string, err error) {
+	m.errors[value] = err
+}
+
+// ErrorForSendMessages makes SendMessages fail with the given error, without
+// reporting which of the messages failed.
+func (m *mockSyncProducer) ErrorForSendMessages(err error) {
+	m.sendMessagesErr = err
+}
+
 func (m *mockSyncProducer) MessageWasProduced(topic string, exp *sarama.ProducerMessage) error {
 	if _, ok := m.producedMessages[topic]; !ok {
 		return fmt.Errorf("0 messages produced for the %s topic", topic)
@@ -37,9 +52,55 @@ func (m *mockSyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition i
 }
 
 func (m *mockSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	if m.sendMessagesErr != nil {
+		return m.sendMessagesErr
+	}
+
+	var errs sarama.ProducerErrors
+	for _, msg := range msgs {
+		val, _ := msg.Value.Encode()
+		if err, ok := m.errors[string(val)]; ok {
+			errs = append(errs, &sarama.ProducerError{Msg: msg, Err: err})
+			continue
+		}
+		m.producedMessages[msg.Topic] = append(m.producedMessages[msg.Topic], msg)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
 	return nil
 }
 
 func (m *mockSyncProducer) Close() error {
 	return nil
 }
+
+func (m *mockSyncProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return sarama.ProducerTxnFlagReady
+}
+
+func (m *mockSyncProducer) IsTransactional() bool {
+	return false
+}
+
+func (m *mockSyncProducer) BeginTxn() error {
+	return nil
+}
+
+func (m *mockSyncProducer) CommitTxn() error {
+	return nil
+}
+
+func (m *mockSyncProducer) AbortTxn() error {
+	return nil
+}
+
+func (m *mockSyncProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupId string) error {
+	return nil
+}
+
+func (m *mockSyncProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupId string, metadata *string) error {
+	return nil
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// mockSchemaRegistry is a local stand-in for a Confluent schema registry,
+// which serves the latest version of each schema registered with it.
+type mockSchemaRegistry struct {
+	*httptest.Server
+	sync.RWMutex
+	schemas  map[string]map[string]any
+	requests int
+}
+
+func NewMockSchemaRegistry() *mockSchemaRegistry {
+	r := &mockSchemaRegistry{
+		schemas: map[string]map[string]any{},
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serveLatestSchema))
+
+	return r
+}
+
+// RegisterSchema registers a new version of the schema for the subject, where
+// schemaType is AVRO, PROTOBUF or JSON.
+func (r *mockSchemaRegistry) RegisterSchema(subject string, id int, schemaType, schema string) {
+	r.Lock()
+	defer r.Unlock()
+
+	version := 1
+	if prev, ok := r.schemas[subject]; ok {
+		version = prev["version"].(int) + 1
+	}
+
+	r.schemas[subject] = map[string]any{
+		"subject":    subject,
+		"id":         id,
+		"version":    version,
+		"schemaType": schemaType,
+		"schema":     schema,
+	}
+}
+
+// Requests returns the number of requests that the registry has served.
+func (r *mockSchemaRegistry) Requests() int {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.requests
+}
+
+func (r *mockSchemaRegistry) serveLatestSchema(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+	r.requests++
+
+	subject := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/subjects/"), "/versions/latest")
+	schema, ok := r.schemas[subject]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error_code":40401,"message":"Subject not found."}`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	_ = json.NewEncoder(w).Encode(schema)
+}
@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"inviqa/kafka-outbox-relay/outbox"
+)
+
+// TopicRewriteRule replaces every match of Pattern in a topic with
+// Replacement, which can refer to the submatches of Pattern, e.g. ${1}.
+type TopicRewriteRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// TopicRewriter rewrites the logical topic names written to the outbox into the
+// topic names that the messages are published to, so that the same application
+// can publish to a differently named topic in each environment, e.g. with a
+// staging. prefix on a shared cluster. Each topic is checked against the
+// Blocked patterns first, then renamed using the Mapping, then rewritten with
+// each of the Rules in order, and finally the Prefix and Suffix are added.
+type TopicRewriter struct {
+	// Blocked holds patterns, e.g. "internal.*", matching the logical topics
+	// that cannot be published to.
+	Blocked []string
+	Mapping map[string]string
+	Rules   []TopicRewriteRule
+	Prefix  string
+	Suffix  string
+}
+
+// Rewrite returns the topic that messages for the logical topic are published
+// to. A permanent error is returned if the topic is blocked, as it will never be
+// possible to publish its messages. A nil TopicRewriter leaves every topic as
+// it is.
+func (r *TopicRewriter) Rewrite(topic string) (string, error) {
+	if r == nil {
+		return topic, nil
+	}
+
+	for _, pattern := range r.Blocked {
+		if ok, _ := path.Match(pattern, topic); ok {
+			return "", outbox.NewPermanentError(fmt.Errorf("the topic %s is blocked from being published to", topic))
+		}
+	}
+
+	rewritten := topic
+	if mapped, ok := r.Mapping[rewritten]; ok {
+		rewritten = mapped
+	}
+
+	for _, rule := range r.Rules {
+		rewritten = rule.Pattern.ReplaceAllString(rewritten, rule.Replacement)
+	}
+
+	rewritten = r.Prefix + rewritten + r.Suffix
+	if rewritten == "" {
+		return "", outbox.NewPermanentError(fmt.Errorf("the topic %s was rewritten to an empty topic", topic))
+	}
+
+	return rewritten, nil
+}
@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"regexp"
+	"testing"
+
+	"inviqa/kafka-outbox-relay/outbox"
+)
+
+func TestTopicRewriter_Rewrite(t *testing.T) {
+	r := &TopicRewriter{
+		Blocked: []string{"internal.*"},
+		Mapping: map[string]string{"productUpdate": "catalog.product-updated"},
+		Rules: []TopicRewriteRule{
+			{Pattern: regexp.MustCompile(`^orders\.v(\d+)$`), Replacement: "orders-v${1}"},
+		},
+		Prefix: "staging.",
+		Suffix: ".eu",
+	}
+
+	for topic, exp := range map[string]string{
+		"productUpdate": "staging.catalog.product-updated.eu",
+		"orders.v2":     "staging.orders-v2.eu",
+		"customers":     "staging.customers.eu",
+	} {
+		got, err := r.Rewrite(topic)
+		if err != nil {
+			t.Errorf("unexpected error rewriting %s: %s", topic, err)
+		}
+
+		if got != exp {
+			t.Errorf("expected %s to be rewritten to %s, but got %s", topic, exp, got)
+		}
+	}
+
+	_, err := r.Rewrite("internal.audit")
+	if class := outbox.ClassOf(err); err == nil || class != outbox.Permanent {
+		t.Errorf("expected a permanent error for the blocked topic, but got %v", err)
+	}
+}
+
+func TestTopicRewriter_RewriteWhenNil(t *testing.T) {
+	var r *TopicRewriter
+
+	if got, err := r.Rewrite("productUpdate"); err != nil || got != "productUpdate" {
+		t.Errorf("expected the topic to be left as it is, but got %s (%v)", got, err)
+	}
+}
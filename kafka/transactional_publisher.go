@@ -0,0 +1,58 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// TransactionalPublisher is a Publisher that is able to wrap the messages it
+// produces in a Kafka transaction, so that consumers using the read_committed
+// isolation level only ever see them once the transaction has been committed.
+type TransactionalPublisher struct {
+	Publisher
+}
+
+func NewTransactionalPublisher(kafkaHost []string, cfg *sarama.Config, transactionalId string) TransactionalPublisher {
+	EnableTransactions(cfg, transactionalId)
+
+	return NewTransactionalPublisherWithProducer(newProducer(cfg, kafkaHost))
+}
+
+func NewTransactionalPublisherWithProducer(prod sarama.SyncProducer) TransactionalPublisher {
+	return TransactionalPublisher{
+		Publisher: NewPublisherWithProducer(prod),
+	}
+}
+
+// NewTransactionalPublisherWithOptions creates a TransactionalPublisher that
+// uses the options to build each message before it is published.
+func NewTransactionalPublisherWithOptions(prod sarama.SyncProducer, opts PublisherOptions) TransactionalPublisher {
+	return TransactionalPublisher{
+		Publisher: NewPublisherWithOptions(prod, opts),
+	}
+}
+
+func (p TransactionalPublisher) BeginTransaction() error {
+	if err := p.producer.BeginTxn(); err != nil {
+		return fmt.Errorf("error beginning Kafka transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (p TransactionalPublisher) CommitTransaction() error {
+	if err := p.producer.CommitTxn(); err != nil {
+		return fmt.Errorf("error committing Kafka transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (p TransactionalPublisher) AbortTransaction() error {
+	if err := p.producer.AbortTxn(); err != nil {
+		return fmt.Errorf("error aborting Kafka transaction: %w", err)
+	}
+
+	return nil
+}
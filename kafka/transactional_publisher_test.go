@@ -0,0 +1,88 @@
+package kafka
+
+import (
+	"testing"
+
+	"inviqa/kafka-outbox-relay/outbox"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/go-test/deep"
+)
+
+func TestNewTransactionalPublisherWithProducer(t *testing.T) {
+	deep.CompareUnexportedFields = true
+	deep.MaxDepth = 3
+	defer func() {
+		deep.CompareUnexportedFields = false
+		deep.MaxDepth = 10
+	}()
+
+	prod := mocks.NewSyncProducer(t, newTransactionalSaramaConfig())
+	exp := TransactionalPublisher{
+		Publisher: Publisher{
+			producer: prod,
+		},
+	}
+
+	if diff := deep.Equal(exp, NewTransactionalPublisherWithProducer(prod)); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestTransactionalPublisher_CommitTransaction(t *testing.T) {
+	prod := mocks.NewSyncProducer(t, newTransactionalSaramaConfig())
+	pub := NewTransactionalPublisherWithProducer(prod)
+
+	prod.ExpectSendMessageAndSucceed()
+
+	if err := pub.BeginTransaction(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if prod.TxnStatus()&sarama.ProducerTxnFlagInTransaction == 0 {
+		t.Error("expected the producer to be in a transaction")
+	}
+
+	msg := &outbox.Message{
+		Id:          1,
+		PayloadJson: []byte(`{"payload"}`),
+		Topic:       "productUpdate",
+	}
+
+	if err := pub.PublishMessage(msg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := pub.CommitTransaction(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if prod.TxnStatus() != sarama.ProducerTxnFlagReady {
+		t.Errorf("expected the producer to be ready after committing, but status was %s", prod.TxnStatus())
+	}
+}
+
+func TestTransactionalPublisher_AbortTransaction(t *testing.T) {
+	prod := mocks.NewSyncProducer(t, newTransactionalSaramaConfig())
+	pub := NewTransactionalPublisherWithProducer(prod)
+
+	if err := pub.BeginTransaction(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := pub.AbortTransaction(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if prod.TxnStatus() != sarama.ProducerTxnFlagReady {
+		t.Errorf("expected the producer to be ready after aborting, but status was %s", prod.TxnStatus())
+	}
+}
+
+func newTransactionalSaramaConfig() *sarama.Config {
+	cfg := NewSaramaConfig(false, false)
+	EnableTransactions(cfg, "relay-db-0")
+
+	return cfg
+}
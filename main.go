@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 	"os/signal"
 	"syscall"
@@ -28,6 +29,13 @@ func main() {
 		log.Logger.Fatalf("unable to create configuration: %s", err)
 	}
 
+	// printing the configuration lets it be validated, e.g. in CI, without
+	// connecting to any of the databases
+	if cfg.PrintConfig {
+		printConfig(cfg)
+		return
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
@@ -37,6 +45,7 @@ func main() {
 
 	dbs, dbClose := data.NewDBs(cfg)
 	defer dbClose()
+	dbs.WatchSecrets(ctx, cfg.SecretsPollInterval)
 
 	var exitCode int
 	switch {
@@ -44,6 +53,8 @@ func main() {
 		exitCode = job.RunCleanup(ctx, nrApp, dbs, cfg)
 	case cfg.RunOptimize:
 		exitCode = job.RunOptimize(ctx, nrApp, dbs, cfg)
+	case cfg.RunRequeueErrored:
+		exitCode = job.RunRequeueErrored(ctx, nrApp, dbs, cfg)
 	default:
 		runMainApp(ctx, nrApp, dbs, cfg)
 	}
@@ -63,13 +74,21 @@ func runMainApp(ctx context.Context, nrApp *nr.Application, dbs data.DBs, cfg *c
 		}
 	}()
 
-	var sizers []prometheus.Sizer
+	sizers := map[string]prometheus.Sizer{}
 	dbs.Each(func(db data.DB) {
 		repo = outbox.NewRepository(db, cfg)
-		cleanups = append(cleanups, poller.Start(ctx, cfg, repo, nrApp))
+		cleanups = append(cleanups, poller.Start(ctx, cfg, db.Config(), repo, nrApp))
+		sizers[db.Config().Name] = repo
 	})
 
-	go prometheus.ObserveQueueSize(ctx, sizers)
-	go prometheus.ObserveTotalSize(ctx, sizers)
+	go prometheus.ObserveSizes(ctx, sizers, cfg.SizeMetricsInterval)
 	prometheus.StartHttpServer(ctx, cfg, dbs)
 }
+
+func printConfig(cfg *config.Config) {
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		log.Logger.Fatalf("unable to print configuration: %s", err)
+	}
+	os.Stdout.Write(append(out, '\n'))
+}
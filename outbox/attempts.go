@@ -0,0 +1,37 @@
+package outbox
+
+import "inviqa/kafka-outbox-relay/config"
+
+// AttemptLimits holds the maximum number of times that publishing a message can
+// fail, for each class of error, before the message is marked as errored.
+type AttemptLimits struct {
+	// Publish limits the attempts that fail with an unclassified error.
+	Publish int
+	// Retry limits the attempts that fail with a retriable error.
+	Retry int
+}
+
+func NewAttemptLimits(cfg *config.Config) AttemptLimits {
+	return AttemptLimits{
+		Publish: cfg.KafkaPublishAttempts,
+		Retry:   cfg.KafkaRetryAttempts,
+	}
+}
+
+// Exhausted returns whether the message failed on its last attempt, so that it
+// will be marked as errored rather than published again. A message that failed
+// with a permanent error is always exhausted.
+func (l AttemptLimits) Exhausted(m *Message) bool {
+	if m.ErrorReason == nil {
+		return false
+	}
+
+	switch ClassOf(m.ErrorReason) {
+	case Permanent:
+		return true
+	case Retriable:
+		return m.RetryAttempts+1 >= l.Retry
+	default:
+		return m.PushAttempts+1 >= l.Publish
+	}
+}
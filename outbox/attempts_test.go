@@ -0,0 +1,30 @@
+package outbox
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAttemptLimits_Exhausted(t *testing.T) {
+	limits := AttemptLimits{Publish: 3, Retry: 5}
+
+	tests := []struct {
+		name string
+		msg  *Message
+		exp  bool
+	}{
+		{"published messages are not exhausted", &Message{PushAttempts: 2}, false},
+		{"permanent errors are always exhausted", &Message{ErrorReason: NewPermanentError(errors.New("oops"))}, true},
+		{"retriable errors are exhausted on the last retry", &Message{ErrorReason: NewRetriableError(errors.New("oops")), PushAttempts: 2, RetryAttempts: 4}, true},
+		{"retriable errors do not use the publish attempts", &Message{ErrorReason: NewRetriableError(errors.New("oops")), PushAttempts: 2, RetryAttempts: 3}, false},
+		{"unclassified errors are exhausted on the last publish attempt", &Message{ErrorReason: errors.New("oops"), PushAttempts: 2}, true},
+		{"unclassified errors do not use the retry attempts", &Message{ErrorReason: errors.New("oops"), PushAttempts: 1, RetryAttempts: 4}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := limits.Exhausted(tt.msg); actual != tt.exp {
+				t.Errorf("expected Exhausted() to return %t, but got %t", tt.exp, actual)
+			}
+		})
+	}
+}
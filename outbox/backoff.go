@@ -0,0 +1,37 @@
+package outbox
+
+import (
+	"math/rand"
+	"time"
+)
+
+// jitter returns a random duration in [0, n), and is a variable so that
+// tests can make it deterministic.
+var jitter = func(n time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(n)))
+}
+
+// retryDelay returns how long to wait before publishing a message again after
+// its attempts-th failed attempt. The delay doubles with each attempt, starting
+// at base and capped at max, and half of it is randomised so that messages
+// which failed together are not all retried at the same time.
+func retryDelay(attempts int, base, max time.Duration) time.Duration {
+	if base <= 0 || attempts < 1 {
+		return 0
+	}
+
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	half := delay / 2
+	if half == 0 {
+		return delay
+	}
+
+	return delay - half + jitter(half)
+}
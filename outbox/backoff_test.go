@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	defer func(orig func(time.Duration) time.Duration) { jitter = orig }(jitter)
+
+	tests := []struct {
+		name     string
+		attempts int
+		base     time.Duration
+		max      time.Duration
+		jitter   func(time.Duration) time.Duration
+		want     time.Duration
+	}{
+		{
+			name:     "no delay when the base delay is zero",
+			attempts: 1,
+			base:     0,
+			max:      time.Minute,
+			want:     0,
+		},
+		{
+			name:     "first attempt with no jitter",
+			attempts: 1,
+			base:     time.Second,
+			max:      time.Minute,
+			jitter:   func(time.Duration) time.Duration { return 0 },
+			want:     time.Millisecond * 500,
+		},
+		{
+			name:     "first attempt with maximum jitter",
+			attempts: 1,
+			base:     time.Second,
+			max:      time.Minute,
+			jitter:   func(n time.Duration) time.Duration { return n - 1 },
+			want:     time.Second - 1,
+		},
+		{
+			name:     "delay doubles with each attempt",
+			attempts: 4,
+			base:     time.Second,
+			max:      time.Minute,
+			jitter:   func(time.Duration) time.Duration { return 0 },
+			want:     time.Second * 4,
+		},
+		{
+			name:     "delay is capped at the max delay",
+			attempts: 100,
+			base:     time.Second,
+			max:      time.Minute,
+			jitter:   func(time.Duration) time.Duration { return 0 },
+			want:     time.Second * 30,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jitter = tt.jitter
+			if got := retryDelay(tt.attempts, tt.base, tt.max); got != tt.want {
+				t.Errorf("retryDelay() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
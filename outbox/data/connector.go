@@ -0,0 +1,42 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"inviqa/kafka-outbox-relay/config"
+)
+
+// secretConnector connects to a database whose password is read from a file,
+// reading the file again for each new connection, so that the connection pool
+// picks up a rotated password without having to be recreated.
+type secretConnector struct {
+	driver driver.Driver
+	cfg    config.Database
+}
+
+func (c secretConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dbCfg := c.cfg
+	pass, err := config.ReadSecretFile(dbCfg.PasswordFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the database password: %w", err)
+	}
+	dbCfg.Password = pass
+
+	dc, ok := c.driver.(driver.DriverContext)
+	if !ok {
+		return c.driver.Open(dbCfg.GetDSN())
+	}
+
+	conn, err := dc.OpenConnector(dbCfg.GetDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.Connect(ctx)
+}
+
+func (c secretConnector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"inviqa/kafka-outbox-relay/config"
+)
+
+type fakeDriver struct {
+	dsns []string
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.dsns = append(d.dsns, dsn)
+	return nil, nil
+}
+
+func TestSecretConnector_Connect(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db-pass")
+	drv := &fakeDriver{}
+	conn := secretConnector{
+		driver: drv,
+		cfg: config.Database{
+			Host:         "host",
+			Port:         3306,
+			User:         "user",
+			Password:     "old",
+			PasswordFile: file,
+			Name:         "orders",
+			Driver:       config.MySQL,
+		},
+	}
+
+	for _, pass := range []string{"old", "new"} {
+		if err := os.WriteFile(file, []byte(pass+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := conn.Connect(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if len(drv.dsns) != 2 || !strings.HasPrefix(drv.dsns[0], "user:old@") || !strings.HasPrefix(drv.dsns[1], "user:new@") {
+		t.Errorf("expected each connection to use the password in the file, but got %v", drv.dsns)
+	}
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.Connect(context.Background()); err == nil {
+		t.Error("expected an error when the password file is missing, but got nil")
+	}
+}
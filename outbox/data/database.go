@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -66,7 +67,7 @@ func NewDBs(cfg *config.Config) (DBs, func()) {
 	log.Logger.Debug("connecting to the database")
 
 	for i, dbCfg := range cfg.DBs {
-		db, err := sql.Open(dbCfg.Driver.String(), dbCfg.GetDSN())
+		db, err := openDB(dbCfg)
 		if err != nil {
 			log.Logger.Fatalf("unable to connect to the database: %s", err)
 		}
@@ -90,6 +91,64 @@ func NewDBs(cfg *config.Config) (DBs, func()) {
 	return dbs, cleanup
 }
 
+// openDB opens the database connection pool. If the password is read from a
+// file, then each new connection reads the file again, so that the password
+// can be rotated without restarting the relay.
+func openDB(dbCfg config.Database) (*sql.DB, error) {
+	if err := registerMySQLTLSConfig(dbCfg); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(dbCfg.Driver.String(), dbCfg.GetDSN())
+	if err != nil || dbCfg.PasswordFile == "" {
+		return db, err
+	}
+
+	drv := db.Driver()
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+
+	return sql.OpenDB(secretConnector{driver: drv, cfg: dbCfg}), nil
+}
+
+// registerMySQLTLSConfig registers the TLS config for a MySQL database that has
+// TLS files, e.g. a CA bundle, with the MySQL driver, as the driver can only
+// refer to a custom TLS config by name in its DSN.
+func registerMySQLTLSConfig(dbCfg config.Database) error {
+	if !dbCfg.Driver.MySQL() || !dbCfg.TLSEnable || !dbCfg.TLSFiles.Configured() {
+		return nil
+	}
+
+	tlsCfg, err := dbCfg.TLSFiles.TLSConfig(dbCfg.TLSSkipVerifyPeer)
+	if err != nil {
+		return err
+	}
+
+	return mysql.RegisterTLSConfig(dbCfg.MySQLTLSConfigName(), tlsCfg)
+}
+
+// WatchSecrets watches the password file of each database, if it has one, and
+// closes the idle connections in its pool when the password changes, so that
+// the pool is rebuilt with connections using the new password. Connections in
+// use by in-flight batches are left to finish, and are replaced once they
+// reach their maximum lifetime.
+func (dbs DBs) WatchSecrets(ctx context.Context, interval time.Duration) {
+	for _, db := range dbs {
+		if db.cfg.PasswordFile == "" {
+			continue
+		}
+
+		go config.WatchSecretFile(ctx, db.cfg.PasswordFile, interval, db.closeIdleConnections)
+	}
+}
+
+func (db DB) closeIdleConnections() {
+	log.Logger.WithField("database", db.cfg.Name).Info("reconnecting to the database with its new password")
+	db.db.SetMaxIdleConns(0)
+	db.db.SetMaxIdleConns(maxIdleConnections)
+}
+
 func connectToDatabase(dbCfg config.Database, db *sql.DB, skipMigrations bool) {
 	tries := connectionAttempts
 	for {
@@ -112,5 +171,5 @@ func connectToDatabase(dbCfg config.Database, db *sql.DB, skipMigrations bool) {
 		return
 	}
 
-	migrateDatabase(dbCfg.Driver, dbCfg.Name, db)
+	migrateDatabase(dbCfg, db)
 }
@@ -1,8 +1,13 @@
 package data
 
 import (
+	"bytes"
 	"database/sql"
 	"embed"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
 
 	"inviqa/kafka-outbox-relay/config"
 	"inviqa/kafka-outbox-relay/log"
@@ -16,7 +21,8 @@ import (
 )
 
 const (
-	migrationsTable = "kafka_outbox_schema_migrations"
+	defaultOutboxTable = "kafka_outbox"
+	migrationsTable    = "kafka_outbox_schema_migrations"
 )
 
 var (
@@ -26,23 +32,25 @@ var (
 	postgresFiles embed.FS
 )
 
-func migrateDatabase(cfgDriver config.DbDriver, databaseName string, db *sql.DB) {
-	log.Logger.Infof("checking database migrations for '%s'", databaseName)
+func migrateDatabase(dbCfg config.Database, db *sql.DB) {
+	log.Logger.Infof("checking database migrations for '%s'", dbCfg.Name)
+
+	tmpl := newMigrationTemplate(dbCfg.Driver, dbCfg.OutboxTable)
 
 	var err error
 	var driver database.Driver
-	if cfgDriver.MySQL() {
-		driver, err = mysql.WithInstance(db, &mysql.Config{MigrationsTable: migrationsTable})
+	if dbCfg.Driver.MySQL() {
+		driver, err = mysql.WithInstance(db, &mysql.Config{MigrationsTable: tmpl.migrationsTable()})
 	} else {
-		driver, err = postgres.WithInstance(db, &postgres.Config{MigrationsTable: migrationsTable})
+		driver, err = postgres.WithInstance(db, &postgres.Config{MigrationsTable: tmpl.migrationsTable(), SchemaName: tmpl.schema})
 	}
 
 	if err != nil {
 		log.Logger.Fatalf("unable to create migration instance from database: %s", err)
 	}
 
-	d := createMigrateSourceDriver(cfgDriver)
-	m, err := migrate.NewWithInstance("iofs", d, databaseName, driver)
+	d := templatedSource{Driver: createMigrateSourceDriver(dbCfg.Driver), tmpl: tmpl}
+	m, err := migrate.NewWithInstance("iofs", d, dbCfg.Name, driver)
 	if err != nil {
 		log.Logger.Fatalf("failed to load migration files from source driver: %s", err)
 	}
@@ -69,3 +77,114 @@ func createMigrateSourceDriver(driver config.DbDriver) source.Driver {
 
 	return d
 }
+
+// migrationTemplate is the data used to render each migration file for the
+// configured outbox table, which may be qualified by its schema.
+type migrationTemplate struct {
+	driver config.DbDriver
+	schema string
+	table  string
+}
+
+func newMigrationTemplate(driver config.DbDriver, outboxTable string) migrationTemplate {
+	t := migrationTemplate{driver: driver, table: outboxTable}
+	if i := strings.LastIndex(outboxTable, "."); i >= 0 {
+		t.schema, t.table = outboxTable[:i], outboxTable[i+1:]
+	}
+
+	return t
+}
+
+// Table is the quoted outbox table name.
+func (t migrationTemplate) Table() string {
+	if t.schema == "" {
+		return t.driver.QuoteIdentifier(t.table)
+	}
+
+	return t.driver.QuoteIdentifier(t.schema + "." + t.table)
+}
+
+// Index is the quoted name of the index on the outbox table. Indexes on the
+// default outbox table keep their original names, so that existing databases
+// are still able to run the down migrations.
+func (t migrationTemplate) Index(name string) string {
+	return t.driver.QuoteIdentifier(t.indexName(name))
+}
+
+// QualifiedIndex is the quoted name of the index on the outbox table, qualified
+// by its schema, for dropping the index in Postgres.
+func (t migrationTemplate) QualifiedIndex(name string) string {
+	if t.schema == "" {
+		return t.Index(name)
+	}
+
+	return t.driver.QuoteIdentifier(t.schema + "." + t.indexName(name))
+}
+
+func (t migrationTemplate) indexName(name string) string {
+	if t.table == defaultOutboxTable {
+		return name
+	}
+
+	return t.table + "_" + name
+}
+
+// migrationsTable is the table used to track the migrations that have been
+// applied to the outbox table, which is stored in the same schema. Only
+// Postgres outbox tables can be qualified by their schema, as the MySQL driver
+// always creates this table in the database that it is connected to.
+func (t migrationTemplate) migrationsTable() string {
+	if t.table == defaultOutboxTable {
+		return migrationsTable
+	}
+
+	return t.table + "_schema_migrations"
+}
+
+// templatedSource renders each migration that is read from the underlying
+// source driver using the migration template.
+type templatedSource struct {
+	source.Driver
+	tmpl migrationTemplate
+}
+
+func (s templatedSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	r, id, err := s.Driver.ReadUp(version)
+	if err != nil {
+		return nil, id, err
+	}
+
+	rendered, err := s.render(r)
+	return rendered, id, err
+}
+
+func (s templatedSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	r, id, err := s.Driver.ReadDown(version)
+	if err != nil {
+		return nil, id, err
+	}
+
+	rendered, err := s.render(r)
+	return rendered, id, err
+}
+
+func (s templatedSource) render(r io.ReadCloser) (io.ReadCloser, error) {
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := template.New("migration").Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse migration template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, s.tmpl); err != nil {
+		return nil, fmt.Errorf("unable to render migration template: %w", err)
+	}
+
+	return io.NopCloser(&buf), nil
+}
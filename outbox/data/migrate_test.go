@@ -0,0 +1,103 @@
+package data
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"inviqa/kafka-outbox-relay/config"
+)
+
+func TestTemplatedSource_RendersEveryMigration(t *testing.T) {
+	tests := []struct {
+		name        string
+		driver      config.DbDriver
+		outboxTable string
+		expCreate   string
+		expIndex    string
+	}{
+		{
+			name:        "mysql default table",
+			driver:      config.MySQL,
+			outboxTable: "kafka_outbox",
+			expCreate:   "CREATE TABLE IF NOT EXISTS `kafka_outbox`(",
+			expIndex:    "CREATE INDEX `outbox_push_completed_at` ON `kafka_outbox`(push_completed_at);",
+		},
+		{
+			name:        "postgres default table",
+			driver:      config.Postgres,
+			outboxTable: "kafka_outbox",
+			expCreate:   `CREATE TABLE IF NOT EXISTS "kafka_outbox"(`,
+			expIndex:    `CREATE INDEX IF NOT EXISTS "outbox_push_completed_at" ON "kafka_outbox"(push_completed_at);`,
+		},
+		{
+			name:        "postgres table qualified by schema",
+			driver:      config.Postgres,
+			outboxTable: "events.outbox",
+			expCreate:   `CREATE TABLE IF NOT EXISTS "events"."outbox"(`,
+			expIndex:    `CREATE INDEX IF NOT EXISTS "outbox_outbox_push_completed_at" ON "events"."outbox"(push_completed_at);`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := templatedSource{Driver: createMigrateSourceDriver(tt.driver), tmpl: newMigrationTemplate(tt.driver, tt.outboxTable)}
+			defer s.Close()
+
+			var migrations []string
+			version, err := s.First()
+			for err == nil {
+				migrations = append(migrations, readMigration(t, s.ReadUp, version), readMigration(t, s.ReadDown, version))
+				version, err = s.Next(version)
+			}
+
+			all := strings.Join(migrations, "\n")
+			if strings.Contains(all, "{{") {
+				t.Errorf("not every migration was rendered: %s", all)
+			}
+			if !strings.Contains(all, tt.expCreate) {
+				t.Errorf("expected the migrations to contain '%s'", tt.expCreate)
+			}
+			if !strings.Contains(all, tt.expIndex) {
+				t.Errorf("expected the migrations to contain '%s'", tt.expIndex)
+			}
+		})
+	}
+}
+
+func TestMigrationTemplate(t *testing.T) {
+	tmpl := newMigrationTemplate(config.Postgres, "events.outbox")
+
+	if got := tmpl.QualifiedIndex("outbox_batch_query"); got != `"events"."outbox_outbox_batch_query"` {
+		t.Errorf("unexpected qualified index name: %s", got)
+	}
+	if got := tmpl.migrationsTable(); got != "outbox_schema_migrations" {
+		t.Errorf("unexpected migrations table name: %s", got)
+	}
+
+	tmpl = newMigrationTemplate(config.MySQL, "kafka_outbox")
+	if got := tmpl.migrationsTable(); got != migrationsTable {
+		t.Errorf("expected the default migrations table name, but got %s", got)
+	}
+}
+
+func readMigration(t *testing.T, read func(uint) (io.ReadCloser, string, error), version uint) string {
+	r, _, err := read(version)
+	if errors.Is(err, fs.ErrNotExist) {
+		// not every migration has a down migration
+		return ""
+	}
+	if err != nil {
+		t.Fatalf("unexpected error reading migration %d: %s", version, err)
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading migration %d: %s", version, err)
+	}
+
+	return string(b)
+}
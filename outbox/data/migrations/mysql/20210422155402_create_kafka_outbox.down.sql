@@ -1 +1 @@
-DROP TABLE IF EXISTS kafka_outbox;
+DROP TABLE IF EXISTS {{ .Table }};
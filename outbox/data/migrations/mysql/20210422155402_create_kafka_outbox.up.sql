@@ -1,4 +1,4 @@
-CREATE TABLE IF NOT EXISTS kafka_outbox(
+CREATE TABLE IF NOT EXISTS {{ .Table }}(
     id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
     batch_id CHAR(36) NULL,
     push_started_at DATETIME NULL,
@@ -12,4 +12,4 @@ CREATE TABLE IF NOT EXISTS kafka_outbox(
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE INDEX outbox_push_completed_at ON kafka_outbox(push_completed_at);
+CREATE INDEX {{ .Index "outbox_push_completed_at" }} ON {{ .Table }}(push_completed_at);
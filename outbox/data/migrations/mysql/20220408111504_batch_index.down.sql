@@ -1 +1 @@
-DELETE INDEX outbox_batch_query;
+DROP INDEX {{ .Index "outbox_batch_query" }} ON {{ .Table }};
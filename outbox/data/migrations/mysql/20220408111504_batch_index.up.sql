@@ -1 +1 @@
-CREATE INDEX outbox_batch_query ON kafka_outbox(batch_id, created_at);
+CREATE INDEX {{ .Index "outbox_batch_query" }} ON {{ .Table }}(batch_id, created_at);
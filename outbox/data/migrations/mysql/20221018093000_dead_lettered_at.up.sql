@@ -0,0 +1 @@
+ALTER TABLE {{ .Table }} ADD COLUMN `dead_lettered_at` DATETIME NULL;
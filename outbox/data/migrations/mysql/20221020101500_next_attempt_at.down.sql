@@ -0,0 +1 @@
+ALTER TABLE {{ .Table }} DROP COLUMN `next_attempt_at`;
@@ -0,0 +1 @@
+ALTER TABLE {{ .Table }} ADD COLUMN `next_attempt_at` DATETIME NULL;
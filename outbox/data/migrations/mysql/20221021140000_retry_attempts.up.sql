@@ -0,0 +1 @@
+ALTER TABLE {{ .Table }} ADD COLUMN `retry_attempts` INT NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE {{ .Table }} ADD COLUMN `error_class` VARCHAR(20) NULL;
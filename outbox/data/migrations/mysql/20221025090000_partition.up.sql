@@ -0,0 +1 @@
+ALTER TABLE {{ .Table }} ADD COLUMN `partition` INT NULL;
@@ -0,0 +1 @@
+ALTER TABLE {{ .Table }} ADD COLUMN `tombstone` TINYINT NOT NULL DEFAULT 0;
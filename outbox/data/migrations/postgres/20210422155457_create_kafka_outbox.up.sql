@@ -1,4 +1,4 @@
-CREATE TABLE IF NOT EXISTS kafka_outbox(
+CREATE TABLE IF NOT EXISTS {{ .Table }}(
     id SERIAL PRIMARY KEY,
     batch_id CHAR(36) NULL,
     push_started_at timestamp NULL,
@@ -12,4 +12,4 @@ CREATE TABLE IF NOT EXISTS kafka_outbox(
     created_at timestamp DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE INDEX IF NOT EXISTS outbox_push_completed_at ON kafka_outbox(push_completed_at);
+CREATE INDEX IF NOT EXISTS {{ .Index "outbox_push_completed_at" }} ON {{ .Table }}(push_completed_at);
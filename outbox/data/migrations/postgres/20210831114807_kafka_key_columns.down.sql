@@ -1,2 +1,2 @@
-ALTER TABLE kafka_outbox DROP COLUMN partition_key;
-ALTER TABLE kafka_outbox DROP COLUMN key;
+ALTER TABLE {{ .Table }} DROP COLUMN partition_key;
+ALTER TABLE {{ .Table }} DROP COLUMN key;
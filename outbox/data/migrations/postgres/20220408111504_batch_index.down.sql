@@ -1 +1 @@
-DROP INDEX IF EXISTS outbox_batch_query;
+DROP INDEX IF EXISTS {{ .QualifiedIndex "outbox_batch_query" }};
@@ -1 +1 @@
-CREATE INDEX IF NOT EXISTS outbox_batch_query ON kafka_outbox(batch_id, created_at);
+CREATE INDEX IF NOT EXISTS {{ .Index "outbox_batch_query" }} ON {{ .Table }}(batch_id, created_at);
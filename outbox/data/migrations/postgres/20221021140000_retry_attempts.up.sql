@@ -0,0 +1 @@
+ALTER TABLE {{ .Table }} ADD COLUMN retry_attempts integer NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE {{ .Table }} ADD COLUMN error_class varchar(20) NULL;
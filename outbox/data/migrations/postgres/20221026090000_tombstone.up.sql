@@ -0,0 +1 @@
+ALTER TABLE {{ .Table }} ADD COLUMN tombstone smallint NOT NULL DEFAULT 0;
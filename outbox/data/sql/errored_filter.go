@@ -0,0 +1,52 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErroredFilter restricts which errored outbox messages are matched when
+// counting or requeueing them. Any zero-valued field is not used as a filter.
+type ErroredFilter struct {
+	Topic              string
+	MinId              int64
+	MaxId              int64
+	CreatedAfter       time.Time
+	CreatedBefore      time.Time
+	ErrorReasonPattern string
+}
+
+// conditions builds the WHERE clause matching errored messages for the filter,
+// using the placeholder func to generate each driver specific placeholder
+// from its 1-based position.
+func (f ErroredFilter) conditions(placeholder func(pos int) string) (string, []any) {
+	where := []string{"errored = 1", "push_completed_at IS NULL"}
+	var args []any
+
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, placeholder(len(args))))
+	}
+
+	if f.Topic != "" {
+		add("topic = %s", f.Topic)
+	}
+	if f.MinId > 0 {
+		add("id >= %s", f.MinId)
+	}
+	if f.MaxId > 0 {
+		add("id <= %s", f.MaxId)
+	}
+	if !f.CreatedAfter.IsZero() {
+		add("created_at >= %s", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		add("created_at <= %s", f.CreatedBefore)
+	}
+	if f.ErrorReasonPattern != "" {
+		add("error_reason LIKE %s", f.ErrorReasonPattern)
+	}
+
+	return strings.Join(where, " AND "), args
+}
@@ -6,30 +6,55 @@ import (
 )
 
 type MysqlQueryProvider struct {
+	// Table is the outbox table name, which must already be quoted.
 	Table   string
 	Columns []string
 }
 
 func (m MysqlQueryProvider) MessagesSuccessUpdateSql(idCount int) string {
-	q := `UPDATE %s SET push_completed_at = NOW(), error_reason = "", push_attempts = push_attempts + 1 WHERE id IN (%s)`
+	q := `UPDATE %s SET push_completed_at = NOW(), error_reason = "", error_class = NULL, push_attempts = push_attempts + 1 WHERE id IN (%s)`
 
 	return fmt.Sprintf(q, m.Table, strings.Trim(strings.Repeat("?, ", idCount), ", "))
 }
 
 func (m MysqlQueryProvider) MessageErroredUpdateSql(maxPushAttempts int) string {
-	q := "UPDATE `%s` SET `error_reason` = ?, `errored` = IF((`push_attempts` + 1) >= %d, 1, 0), `push_started_at` = NULL, `batch_id` = NULL, `push_attempts` = `push_attempts` + 1 WHERE `id` = ?"
+	q := "UPDATE %s SET `error_reason` = ?, `error_class` = ?, `errored` = IF((`push_attempts` + 1) >= %d, 1, 0), `next_attempt_at` = DATE_ADD(NOW(), INTERVAL ? MICROSECOND), `push_started_at` = NULL, `batch_id` = NULL, `push_attempts` = `push_attempts` + 1 WHERE `id` = ?"
 
 	return fmt.Sprintf(q, m.Table, maxPushAttempts)
 }
 
+func (m MysqlQueryProvider) MessageRetryUpdateSql(maxRetryAttempts int) string {
+	q := "UPDATE %s SET `error_reason` = ?, `error_class` = ?, `errored` = IF((`retry_attempts` + 1) >= %d, 1, 0), `next_attempt_at` = DATE_ADD(NOW(), INTERVAL ? MICROSECOND), `push_started_at` = NULL, `batch_id` = NULL, `retry_attempts` = `retry_attempts` + 1 WHERE `id` = ?"
+
+	return fmt.Sprintf(q, m.Table, maxRetryAttempts)
+}
+
+func (m MysqlQueryProvider) MessagePermanentlyErroredUpdateSql() string {
+	q := "UPDATE %s SET `error_reason` = ?, `error_class` = ?, `errored` = 1, `push_started_at` = NULL, `batch_id` = NULL, `push_attempts` = `push_attempts` + 1 WHERE `id` = ?"
+
+	return fmt.Sprintf(q, m.Table)
+}
+
+func (m MysqlQueryProvider) MessageDeadLetteredUpdateSql() string {
+	q := "UPDATE %s SET `error_reason` = ?, `error_class` = ?, `errored` = 1, `dead_lettered_at` = NOW(), `push_started_at` = NULL, `batch_id` = NULL, `push_attempts` = `push_attempts` + 1 WHERE `id` = ?"
+
+	return fmt.Sprintf(q, m.Table)
+}
+
 func (m MysqlQueryProvider) BatchCreationSql(batchSize int) string {
 	q := `UPDATE %s SET batch_id = ?, push_started_at = NOW()
-		WHERE ((batch_id IS NULL AND push_started_at IS NULL) OR
-		(batch_id IS NOT NULL AND push_completed_at IS NULL AND push_started_at < ?)) AND errored = ? ORDER BY created_at ASC LIMIT %d`
+		WHERE batch_id IS NULL AND push_started_at IS NULL AND errored = ? AND (next_attempt_at IS NULL OR next_attempt_at <= NOW()) ORDER BY created_at ASC LIMIT %d`
 
 	return fmt.Sprintf(q, m.Table, batchSize)
 }
 
+func (m MysqlQueryProvider) StaleBatchReleaseSql() string {
+	q := `UPDATE %s SET batch_id = NULL, push_started_at = NULL
+		WHERE batch_id IS NOT NULL AND push_completed_at IS NULL AND push_started_at < ? AND errored = ?`
+
+	return fmt.Sprintf(q, m.Table)
+}
+
 func (m MysqlQueryProvider) BatchFetchSql() string {
 	return fmt.Sprintf(`SELECT %s FROM %s WHERE batch_id = ? ORDER BY created_at ASC`, strings.Join(m.escapeColumns(), ", "), m.Table)
 }
@@ -38,12 +63,29 @@ func (m MysqlQueryProvider) DeletePublishedMessagesSql() string {
 	return fmt.Sprintf("DELETE FROM %s WHERE push_completed_at <= ?", m.Table)
 }
 
-func (m MysqlQueryProvider) GetQueueSizeSql() string {
-	return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE push_completed_at IS NULL", m.Table)
+func (m MysqlQueryProvider) GetQueueSizeByTopicSql() string {
+	return fmt.Sprintf("SELECT topic, COUNT(*) FROM %s WHERE push_completed_at IS NULL GROUP BY topic", m.Table)
+}
+
+func (m MysqlQueryProvider) GetTotalSizeByTopicSql() string {
+	return fmt.Sprintf("SELECT topic, COUNT(*) FROM %s GROUP BY topic", m.Table)
+}
+
+func (m MysqlQueryProvider) OldestUnpublishedAgeSql() string {
+	return fmt.Sprintf("SELECT TIMESTAMPDIFF(MICROSECOND, MIN(created_at), NOW()) FROM %s WHERE push_completed_at IS NULL AND errored = 0", m.Table)
 }
 
-func (m MysqlQueryProvider) GetTotalSizeSql() string {
-	return fmt.Sprintf("SELECT COUNT(*) FROM %s", m.Table)
+func (m MysqlQueryProvider) CountErroredSql(f ErroredFilter) (string, []any) {
+	where, args := f.conditions(func(int) string { return "?" })
+
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", m.Table, where), args
+}
+
+func (m MysqlQueryProvider) RequeueErroredSql(f ErroredFilter) (string, []any) {
+	where, args := f.conditions(func(int) string { return "?" })
+	q := "UPDATE %s SET `errored` = 0, `error_reason` = '', `error_class` = NULL, `push_attempts` = 0, `retry_attempts` = 0, `batch_id` = NULL, `push_started_at` = NULL, `next_attempt_at` = NULL, `dead_lettered_at` = NULL WHERE %s"
+
+	return fmt.Sprintf(q, m.Table, where), args
 }
 
 func (m MysqlQueryProvider) escapeColumns() []string {
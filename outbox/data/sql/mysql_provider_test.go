@@ -3,12 +3,15 @@ package sql
 import (
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-test/deep"
 )
 
 func TestMysqlQueryProvider_MessagesSuccessUpdateSql(t *testing.T) {
 	actual := createProvider().MessagesSuccessUpdateSql(3)
 
-	exp := `UPDATE kafka_outbox SET push_completed_at = NOW(), error_reason = "", push_attempts = push_attempts + 1 WHERE id IN (?, ?, ?)`
+	exp := `UPDATE kafka_outbox SET push_completed_at = NOW(), error_reason = "", error_class = NULL, push_attempts = push_attempts + 1 WHERE id IN (?, ?, ?)`
 
 	if actual != exp {
 		t.Errorf(`received "%s" but expected "%s"`, actual, exp)
@@ -21,6 +24,22 @@ func TestMysqlQueryProvider_BatchCreationSql(t *testing.T) {
 	if !strings.Contains(actual, "LIMIT 20") {
 		t.Errorf("batch creation SQL does not contain the correct batch size limit")
 	}
+
+	if !strings.Contains(actual, "(next_attempt_at IS NULL OR next_attempt_at <= NOW())") {
+		t.Errorf("batch creation SQL does not skip messages that are waiting to be retried")
+	}
+}
+
+func TestMysqlQueryProvider_StaleBatchReleaseSql(t *testing.T) {
+	actual := createProvider().StaleBatchReleaseSql()
+
+	if !strings.Contains(actual, "SET batch_id = NULL, push_started_at = NULL") {
+		t.Errorf("stale batch release SQL does not reset the batch as expected")
+	}
+
+	if !strings.Contains(actual, "push_started_at < ?") {
+		t.Errorf("stale batch release SQL does not contain the staleness constraint")
+	}
 }
 
 func TestMysqlQueryProvider_MessageErroredUpdateSql(t *testing.T) {
@@ -29,6 +48,42 @@ func TestMysqlQueryProvider_MessageErroredUpdateSql(t *testing.T) {
 	if !strings.Contains(actual, "`errored` = IF((`push_attempts` + 1) >= 10, 1, 0)") {
 		t.Errorf("message errored SQL does not set the `errored` property as expected")
 	}
+
+	if !strings.Contains(actual, "`next_attempt_at` = DATE_ADD(NOW(), INTERVAL ? MICROSECOND)") {
+		t.Errorf("message errored SQL does not set the `next_attempt_at` property as expected")
+	}
+}
+
+func TestMysqlQueryProvider_MessageRetryUpdateSql(t *testing.T) {
+	actual := createProvider().MessageRetryUpdateSql(20)
+
+	if strings.Contains(actual, "`push_attempts`") {
+		t.Errorf("message retry SQL should not consume a push attempt")
+	}
+
+	if !strings.Contains(actual, "`errored` = IF((`retry_attempts` + 1) >= 20, 1, 0)") || !strings.Contains(actual, "`retry_attempts` = `retry_attempts` + 1") {
+		t.Errorf("message retry SQL does not count the retry attempts as expected")
+	}
+
+	if !strings.Contains(actual, "`error_class` = ?") {
+		t.Errorf("message retry SQL does not set the `error_class` property as expected")
+	}
+}
+
+func TestMysqlQueryProvider_MessagePermanentlyErroredUpdateSql(t *testing.T) {
+	actual := createProvider().MessagePermanentlyErroredUpdateSql()
+
+	if !strings.Contains(actual, "`errored` = 1, `push_started_at` = NULL") {
+		t.Errorf("message permanently errored SQL does not set the `errored` property as expected")
+	}
+}
+
+func TestMysqlQueryProvider_MessageDeadLetteredUpdateSql(t *testing.T) {
+	actual := createProvider().MessageDeadLetteredUpdateSql()
+
+	if !strings.Contains(actual, "`errored` = 1, `dead_lettered_at` = NOW()") {
+		t.Errorf("message dead-lettered SQL does not set the `dead_lettered_at` property as expected")
+	}
 }
 
 func TestMysqlQueryProvider_DeletePublishedMessagesSql(t *testing.T) {
@@ -48,6 +103,58 @@ func TestMysqlQueryProvider_BatchFetchSql(t *testing.T) {
 	}
 }
 
+func TestMysqlQueryProvider_GetQueueSizeByTopicSql(t *testing.T) {
+	got := createProvider().GetQueueSizeByTopicSql()
+	exp := "SELECT topic, COUNT(*) FROM kafka_outbox WHERE push_completed_at IS NULL GROUP BY topic"
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+}
+
+func TestMysqlQueryProvider_GetTotalSizeByTopicSql(t *testing.T) {
+	got := createProvider().GetTotalSizeByTopicSql()
+	exp := "SELECT topic, COUNT(*) FROM kafka_outbox GROUP BY topic"
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+}
+
+func TestMysqlQueryProvider_OldestUnpublishedAgeSql(t *testing.T) {
+	got := createProvider().OldestUnpublishedAgeSql()
+	exp := "SELECT TIMESTAMPDIFF(MICROSECOND, MIN(created_at), NOW()) FROM kafka_outbox WHERE push_completed_at IS NULL AND errored = 0"
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+}
+
+func TestMysqlQueryProvider_CountErroredSql(t *testing.T) {
+	got, args := createProvider().CountErroredSql(ErroredFilter{Topic: "product", MaxId: 100})
+	exp := "SELECT COUNT(*) FROM kafka_outbox WHERE errored = 1 AND push_completed_at IS NULL AND topic = ? AND id <= ?"
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+	if diff := deep.Equal([]any{"product", int64(100)}, args); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestMysqlQueryProvider_RequeueErroredSql(t *testing.T) {
+	after := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	got, args := createProvider().RequeueErroredSql(ErroredFilter{CreatedAfter: after, ErrorReasonPattern: "%timeout%"})
+	exp := "UPDATE kafka_outbox SET `errored` = 0, `error_reason` = '', `error_class` = NULL, `push_attempts` = 0, `retry_attempts` = 0, `batch_id` = NULL, `push_started_at` = NULL, `next_attempt_at` = NULL, `dead_lettered_at` = NULL WHERE errored = 1 AND push_completed_at IS NULL AND created_at >= ? AND error_reason LIKE ?"
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+	if diff := deep.Equal([]any{after, "%timeout%"}, args); diff != nil {
+		t.Error(diff)
+	}
+}
+
 func createProvider() *MysqlQueryProvider {
 	return &MysqlQueryProvider{
 		Columns: []string{"name", "foo"},
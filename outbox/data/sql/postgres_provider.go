@@ -6,12 +6,13 @@ import (
 )
 
 type PostgresQueryProvider struct {
+	// Table is the outbox table name, which must already be quoted.
 	Table   string
 	Columns []string
 }
 
 func (m PostgresQueryProvider) MessagesSuccessUpdateSql(idCount int) string {
-	q := `UPDATE %s SET push_completed_at = NOW(), error_reason = '', push_attempts = push_attempts + 1 WHERE id IN (%s)`
+	q := `UPDATE %s SET push_completed_at = NOW(), error_reason = '', error_class = NULL, push_attempts = push_attempts + 1 WHERE id IN (%s)`
 
 	var placeholders []string
 	for i := 1; i <= idCount; i++ {
@@ -22,32 +23,86 @@ func (m PostgresQueryProvider) MessagesSuccessUpdateSql(idCount int) string {
 }
 
 func (m PostgresQueryProvider) MessageErroredUpdateSql(maxPushAttempts int) string {
-	q := `UPDATE %s SET error_reason = $1, errored = CASE WHEN push_attempts + 1 >= %d THEN 1 ELSE 0 END, push_started_at = NULL, batch_id = NULL, push_attempts = push_attempts + 1 WHERE id = $2`
+	q := `UPDATE %s SET error_reason = $1, error_class = $2, errored = CASE WHEN push_attempts + 1 >= %d THEN 1 ELSE 0 END, next_attempt_at = NOW() + $3::bigint * INTERVAL '1 microsecond', push_started_at = NULL, batch_id = NULL, push_attempts = push_attempts + 1 WHERE id = $4`
 
 	return fmt.Sprintf(q, m.Table, maxPushAttempts)
 }
 
+func (m PostgresQueryProvider) MessageRetryUpdateSql(maxRetryAttempts int) string {
+	q := `UPDATE %s SET error_reason = $1, error_class = $2, errored = CASE WHEN retry_attempts + 1 >= %d THEN 1 ELSE 0 END, next_attempt_at = NOW() + $3::bigint * INTERVAL '1 microsecond', push_started_at = NULL, batch_id = NULL, retry_attempts = retry_attempts + 1 WHERE id = $4`
+
+	return fmt.Sprintf(q, m.Table, maxRetryAttempts)
+}
+
+func (m PostgresQueryProvider) MessagePermanentlyErroredUpdateSql() string {
+	q := `UPDATE %s SET error_reason = $1, error_class = $2, errored = 1, push_started_at = NULL, batch_id = NULL, push_attempts = push_attempts + 1 WHERE id = $3`
+
+	return fmt.Sprintf(q, m.Table)
+}
+
+func (m PostgresQueryProvider) MessageDeadLetteredUpdateSql() string {
+	q := `UPDATE %s SET error_reason = $1, error_class = $2, errored = 1, dead_lettered_at = NOW(), push_started_at = NULL, batch_id = NULL, push_attempts = push_attempts + 1 WHERE id = $3`
+
+	return fmt.Sprintf(q, m.Table)
+}
+
 func (m PostgresQueryProvider) BatchCreationSql(batchSize int) string {
 	q := `UPDATE %s SET batch_id = $1, push_started_at = NOW()
 		WHERE id IN(
-			SELECT id FROM %s WHERE ((batch_id IS NULL AND push_started_at IS NULL) OR
-		(batch_id IS NOT NULL AND push_completed_at IS NULL AND push_started_at < $2)) AND errored = $3 ORDER BY created_at ASC LIMIT %d)`
+			SELECT id FROM %s WHERE batch_id IS NULL AND push_started_at IS NULL AND errored = $2 AND (next_attempt_at IS NULL OR next_attempt_at <= NOW()) ORDER BY created_at ASC LIMIT %d)`
 
 	return fmt.Sprintf(q, m.Table, m.Table, batchSize)
 }
 
+func (m PostgresQueryProvider) StaleBatchReleaseSql() string {
+	q := `UPDATE %s SET batch_id = NULL, push_started_at = NULL
+		WHERE batch_id IS NOT NULL AND push_completed_at IS NULL AND push_started_at < $1 AND errored = $2`
+
+	return fmt.Sprintf(q, m.Table)
+}
+
 func (m PostgresQueryProvider) BatchFetchSql() string {
-	return fmt.Sprintf(`SELECT %s FROM %s WHERE batch_id = $1 ORDER BY created_at ASC`, strings.Join(m.Columns, ", "), m.Table)
+	return fmt.Sprintf(`SELECT %s FROM %s WHERE batch_id = $1 ORDER BY created_at ASC`, strings.Join(m.escapeColumns(), ", "), m.Table)
 }
 
 func (m PostgresQueryProvider) DeletePublishedMessagesSql() string {
 	return fmt.Sprintf("DELETE FROM %s WHERE push_completed_at <= $1", m.Table)
 }
 
-func (m PostgresQueryProvider) GetQueueSizeSql() string {
-	return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE push_completed_at IS NULL", m.Table)
+func (m PostgresQueryProvider) GetQueueSizeByTopicSql() string {
+	return fmt.Sprintf("SELECT topic, COUNT(*) FROM %s WHERE push_completed_at IS NULL GROUP BY topic", m.Table)
+}
+
+func (m PostgresQueryProvider) GetTotalSizeByTopicSql() string {
+	return fmt.Sprintf("SELECT topic, COUNT(*) FROM %s GROUP BY topic", m.Table)
+}
+
+func (m PostgresQueryProvider) OldestUnpublishedAgeSql() string {
+	return fmt.Sprintf("SELECT CAST(EXTRACT(EPOCH FROM NOW() - MIN(created_at)) * 1000000 AS BIGINT) FROM %s WHERE push_completed_at IS NULL AND errored = 0", m.Table)
+}
+
+func (m PostgresQueryProvider) CountErroredSql(f ErroredFilter) (string, []any) {
+	where, args := f.conditions(postgresPlaceholder)
+
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", m.Table, where), args
+}
+
+func (m PostgresQueryProvider) RequeueErroredSql(f ErroredFilter) (string, []any) {
+	where, args := f.conditions(postgresPlaceholder)
+	q := `UPDATE %s SET errored = 0, error_reason = '', error_class = NULL, push_attempts = 0, retry_attempts = 0, batch_id = NULL, push_started_at = NULL, next_attempt_at = NULL, dead_lettered_at = NULL WHERE %s`
+
+	return fmt.Sprintf(q, m.Table, where), args
+}
+
+func (m PostgresQueryProvider) escapeColumns() []string {
+	var escaped []string
+	for _, c := range m.Columns {
+		escaped = append(escaped, `"`+c+`"`)
+	}
+
+	return escaped
 }
 
-func (m PostgresQueryProvider) GetTotalSizeSql() string {
-	return fmt.Sprintf("SELECT COUNT(*) FROM %s", m.Table)
+func postgresPlaceholder(pos int) string {
+	return fmt.Sprintf("$%d", pos)
 }
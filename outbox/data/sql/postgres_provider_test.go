@@ -3,12 +3,15 @@ package sql
 import (
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-test/deep"
 )
 
 func TestPostgresQueryProvider_MessagesSuccessUpdateSql(t *testing.T) {
 	actual := createPostgresProvider().MessagesSuccessUpdateSql(3)
 
-	exp := `UPDATE kafka_outbox SET push_completed_at = NOW(), error_reason = '', push_attempts = push_attempts + 1 WHERE id IN ($1, $2, $3)`
+	exp := `UPDATE kafka_outbox SET push_completed_at = NOW(), error_reason = '', error_class = NULL, push_attempts = push_attempts + 1 WHERE id IN ($1, $2, $3)`
 
 	if actual != exp {
 		t.Errorf(`received "%s" but expected "%s"`, actual, exp)
@@ -21,6 +24,22 @@ func TestPostgresQueryProvider_BatchCreationSql(t *testing.T) {
 	if !strings.Contains(actual, "LIMIT 20") {
 		t.Errorf("batch creation SQL does not contain the correct batch size limit")
 	}
+
+	if !strings.Contains(actual, "(next_attempt_at IS NULL OR next_attempt_at <= NOW())") {
+		t.Errorf("batch creation SQL does not skip messages that are waiting to be retried")
+	}
+}
+
+func TestPostgresQueryProvider_StaleBatchReleaseSql(t *testing.T) {
+	actual := createPostgresProvider().StaleBatchReleaseSql()
+
+	if !strings.Contains(actual, "SET batch_id = NULL, push_started_at = NULL") {
+		t.Errorf("stale batch release SQL does not reset the batch as expected")
+	}
+
+	if !strings.Contains(actual, "push_started_at < $1") {
+		t.Errorf("stale batch release SQL does not contain the staleness constraint")
+	}
 }
 
 func TestPostgresQueryProvider_MessageErroredUpdateSql(t *testing.T) {
@@ -29,6 +48,51 @@ func TestPostgresQueryProvider_MessageErroredUpdateSql(t *testing.T) {
 	if !strings.Contains(actual, "errored = CASE WHEN push_attempts + 1 >= 3 THEN 1 ELSE 0 END") {
 		t.Errorf("message errored SQL does not set the `errored` property as expected")
 	}
+
+	if !strings.Contains(actual, "next_attempt_at = NOW() + $3::bigint * INTERVAL '1 microsecond'") {
+		t.Errorf("message errored SQL does not set the `next_attempt_at` property as expected")
+	}
+}
+
+func TestPostgresQueryProvider_MessageRetryUpdateSql(t *testing.T) {
+	actual := createPostgresProvider().MessageRetryUpdateSql(20)
+
+	if strings.Contains(actual, "push_attempts") {
+		t.Errorf("message retry SQL should not consume a push attempt")
+	}
+
+	if !strings.Contains(actual, "errored = CASE WHEN retry_attempts + 1 >= 20 THEN 1 ELSE 0 END") || !strings.Contains(actual, "retry_attempts = retry_attempts + 1") {
+		t.Errorf("message retry SQL does not count the retry attempts as expected")
+	}
+
+	if !strings.Contains(actual, "error_class = $2") {
+		t.Errorf("message retry SQL does not set the `error_class` property as expected")
+	}
+}
+
+func TestPostgresQueryProvider_MessagePermanentlyErroredUpdateSql(t *testing.T) {
+	actual := createPostgresProvider().MessagePermanentlyErroredUpdateSql()
+
+	if !strings.Contains(actual, "errored = 1, push_started_at = NULL") {
+		t.Errorf("message permanently errored SQL does not set the errored property as expected")
+	}
+}
+
+func TestPostgresQueryProvider_MessageDeadLetteredUpdateSql(t *testing.T) {
+	actual := createPostgresProvider().MessageDeadLetteredUpdateSql()
+
+	if !strings.Contains(actual, "errored = 1, dead_lettered_at = NOW()") {
+		t.Errorf("message dead-lettered SQL does not set the `dead_lettered_at` property as expected")
+	}
+}
+
+func TestPostgresQueryProvider_BatchFetchSql(t *testing.T) {
+	got := createPostgresProvider().BatchFetchSql()
+	exp := `SELECT "name", "foo" FROM kafka_outbox WHERE batch_id = $1 ORDER BY created_at ASC`
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
 }
 
 func TestPostgresQueryProvider_DeletePublishedMessagesSql(t *testing.T) {
@@ -39,6 +103,59 @@ func TestPostgresQueryProvider_DeletePublishedMessagesSql(t *testing.T) {
 	}
 }
 
+func TestPostgresQueryProvider_GetQueueSizeByTopicSql(t *testing.T) {
+	got := createPostgresProvider().GetQueueSizeByTopicSql()
+	exp := "SELECT topic, COUNT(*) FROM kafka_outbox WHERE push_completed_at IS NULL GROUP BY topic"
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+}
+
+func TestPostgresQueryProvider_GetTotalSizeByTopicSql(t *testing.T) {
+	got := createPostgresProvider().GetTotalSizeByTopicSql()
+	exp := "SELECT topic, COUNT(*) FROM kafka_outbox GROUP BY topic"
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+}
+
+func TestPostgresQueryProvider_OldestUnpublishedAgeSql(t *testing.T) {
+	got := createPostgresProvider().OldestUnpublishedAgeSql()
+	exp := "SELECT CAST(EXTRACT(EPOCH FROM NOW() - MIN(created_at)) * 1000000 AS BIGINT) FROM kafka_outbox WHERE push_completed_at IS NULL AND errored = 0"
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+}
+
+func TestPostgresQueryProvider_CountErroredSql(t *testing.T) {
+	got, args := createPostgresProvider().CountErroredSql(ErroredFilter{})
+	exp := "SELECT COUNT(*) FROM kafka_outbox WHERE errored = 1 AND push_completed_at IS NULL"
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+	if len(args) != 0 {
+		t.Errorf("expected no query args, but got %v", args)
+	}
+}
+
+func TestPostgresQueryProvider_RequeueErroredSql(t *testing.T) {
+	before := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	f := ErroredFilter{Topic: "product", MinId: 10, MaxId: 20, CreatedBefore: before, ErrorReasonPattern: "%too large%"}
+	got, args := createPostgresProvider().RequeueErroredSql(f)
+	exp := "UPDATE kafka_outbox SET errored = 0, error_reason = '', error_class = NULL, push_attempts = 0, retry_attempts = 0, batch_id = NULL, push_started_at = NULL, next_attempt_at = NULL, dead_lettered_at = NULL WHERE errored = 1 AND push_completed_at IS NULL AND topic = $1 AND id >= $2 AND id <= $3 AND created_at <= $4 AND error_reason LIKE $5"
+
+	if got != exp {
+		t.Errorf("expected '%s', but got '%s'", exp, got)
+	}
+	if diff := deep.Equal([]any{"product", int64(10), int64(20), before, "%too large%"}, args); diff != nil {
+		t.Error(diff)
+	}
+}
+
 func createPostgresProvider() *PostgresQueryProvider {
 	return &PostgresQueryProvider{
 		Columns: []string{"name", "foo"},
@@ -0,0 +1,52 @@
+package outbox
+
+import "errors"
+
+const (
+	// Unclassified errors consume a publish attempt, and the message is marked
+	// as errored once it has run out of attempts.
+	Unclassified ErrorClass = "unclassified"
+	// Retriable errors are transient, e.g. broker or network failures, so the
+	// message is retried without consuming a publish attempt, until it has run
+	// out of retry attempts.
+	Retriable ErrorClass = "retriable"
+	// Permanent errors will fail on every attempt, e.g. a message that is too
+	// large, so the message is marked as errored without being retried.
+	Permanent ErrorClass = "permanent"
+)
+
+type ErrorClass string
+
+// ClassifiedError wraps an error that occurred whilst publishing a message
+// with its ErrorClass.
+type ClassifiedError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func NewRetriableError(err error) error {
+	return ClassifiedError{Class: Retriable, Err: err}
+}
+
+func NewPermanentError(err error) error {
+	return ClassifiedError{Class: Permanent, Err: err}
+}
+
+func (e ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// ClassOf returns the ErrorClass of err, which is Unclassified unless err
+// wraps a ClassifiedError.
+func ClassOf(err error) ErrorClass {
+	var ce ClassifiedError
+	if errors.As(err, &ce) {
+		return ce.Class
+	}
+
+	return Unclassified
+}
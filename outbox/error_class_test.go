@@ -0,0 +1,51 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{
+			name: "plain error is unclassified",
+			err:  errors.New("oops"),
+			want: Unclassified,
+		},
+		{
+			name: "retriable error",
+			err:  NewRetriableError(errors.New("oops")),
+			want: Retriable,
+		},
+		{
+			name: "wrapped permanent error",
+			err:  fmt.Errorf("wrapped: %w", NewPermanentError(errors.New("oops"))),
+			want: Permanent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassOf(tt.err); got != tt.want {
+				t.Errorf("ClassOf() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifiedError_Error(t *testing.T) {
+	inner := errors.New("kafka server: Message was too large")
+	err := NewPermanentError(inner)
+
+	if err.Error() != inner.Error() {
+		t.Errorf("expected '%s', but got '%s'", inner, err)
+	}
+
+	if !errors.Is(err, inner) {
+		t.Error("expected the classified error to wrap the original error")
+	}
+}
@@ -7,7 +7,9 @@ import (
 )
 
 type Batch struct {
-	Id       uuid.UUID
+	Id uuid.UUID
+	// Database is the name of the database that the batch was created in.
+	Database string
 	Messages []*Message
 }
 
@@ -20,8 +22,16 @@ type Message struct {
 	PayloadHeaders  []byte
 	Topic           string
 	PushAttempts    int
+	RetryAttempts   int
 	Errored         bool
 	ErrorReason     error
+	DeadLettered    bool
 	Key             string
 	PartitionKey    string
+	Partition       sql.NullInt32
+	Tombstone       bool
+	CreatedAt       sql.NullTime
+	// TraceHeaders holds the distributed trace context of the transaction that
+	// is publishing the message, which is not stored in the outbox.
+	TraceHeaders map[string]string
 }
@@ -9,30 +9,39 @@ import (
 	"inviqa/kafka-outbox-relay/log"
 	"inviqa/kafka-outbox-relay/newrelic"
 	"inviqa/kafka-outbox-relay/outbox"
+	"inviqa/kafka-outbox-relay/prometheus"
 )
 
 type repository interface {
 	GetBatch(ctx context.Context) (*outbox.Batch, error)
 }
 
-func New(r repository, ch chan<- *outbox.Batch, nrApp *nr.Application) *Poller {
+type staleBatchReleaser interface {
+	ReleaseStaleBatches(ctx context.Context) (int64, error)
+}
+
+func New(r repository, ch chan<- *outbox.Batch, database string, nrApp *nr.Application) *Poller {
 	return &Poller{
-		ch:    ch,
-		repo:  r,
-		nrApp: nrApp,
+		ch:       ch,
+		repo:     r,
+		database: database,
+		nrApp:    nrApp,
 	}
 }
 
 type Poller struct {
-	ch    chan<- *outbox.Batch
-	repo  repository
-	nrApp *nr.Application
+	ch       chan<- *outbox.Batch
+	repo     repository
+	database string
+	nrApp    *nr.Application
 }
 
 func (p Poller) Poll(parent context.Context, backoff time.Duration) {
 	for {
 		ctx, txn := newrelic.ContextWithTxn(parent, "outbox: Poller.Poll()", p.nrApp)
+		start := time.Now()
 		batch, err := p.repo.GetBatch(ctx)
+		prometheus.ObserveGetBatchDuration(p.database, time.Since(start))
 		if err != nil {
 			if err != outbox.ErrNoEvents {
 				log.Logger.WithError(err).Errorf("an unexpected error occurred when polling the outbox: %s", err)
@@ -52,3 +61,27 @@ func (p Poller) Poll(parent context.Context, backoff time.Duration) {
 		}
 	}
 }
+
+// ReleaseStaleBatches releases the messages in any stale batches of the
+// database's outbox every interval, until the context is cancelled, so that
+// they are picked up again by the poller. The number of messages released is
+// recorded for the database, whether or not there are any other messages to
+// publish.
+func ReleaseStaleBatches(parent context.Context, r staleBatchReleaser, database string, interval time.Duration, nrApp *nr.Application) {
+	for {
+		ctx, txn := newrelic.ContextWithTxn(parent, "outbox: ReleaseStaleBatches()", nrApp)
+		released, err := r.ReleaseStaleBatches(ctx)
+		if err != nil {
+			log.Logger.WithError(err).WithField("database", database).Error("an unexpected error occurred when releasing stale batches from the outbox")
+			txn.NoticeError(err)
+		}
+		txn.End()
+		prometheus.ObserveReclaimedMessages(database, released)
+
+		select {
+		case <-parent.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
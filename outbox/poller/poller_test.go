@@ -17,7 +17,7 @@ func TestNew(t *testing.T) {
 	repo := test.NewMockRepository()
 	ch := make(chan *outbox.Batch)
 
-	if nil == New(repo, ch, nil) {
+	if nil == New(repo, ch, "orders", nil) {
 		t.Errorf("received nil from New()")
 	}
 }
@@ -33,7 +33,7 @@ func Test_Poller_Poll(t *testing.T) {
 	repoWithBatches.AddBatch(b2)
 
 	t.Run("it polls for events and sends them for processing", func(t *testing.T) {
-		p := New(repoWithBatches, ch, nil)
+		p := New(repoWithBatches, ch, "orders", nil)
 		go p.Poll(context.Background(), time.Millisecond*10)
 
 		readFromChannelUntilBatchReceived(b1, ch, t)
@@ -45,7 +45,7 @@ func Test_Poller_Poll(t *testing.T) {
 		repo.ReturnErrors()
 
 		ctx, cancel := context.WithCancel(context.Background())
-		p := New(repo, ch, nil)
+		p := New(repo, ch, "orders", nil)
 		go p.Poll(ctx, time.Second*200)
 
 		time.Sleep(time.Millisecond * 100)
@@ -61,7 +61,7 @@ func Test_Poller_Poll(t *testing.T) {
 		repo.ReturnNoEventsError()
 
 		ctx, cancel := context.WithCancel(context.Background())
-		p := New(repo, ch, nil)
+		p := New(repo, ch, "orders", nil)
 		go p.Poll(ctx, time.Second*200)
 
 		time.Sleep(time.Millisecond * 100)
@@ -74,7 +74,7 @@ func Test_Poller_Poll(t *testing.T) {
 
 	t.Run("it stops goroutine when context is cancelled", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
-		p := New(repoWithBatches, ch, nil)
+		p := New(repoWithBatches, ch, "orders", nil)
 		go p.Poll(ctx, time.Millisecond*10)
 
 		routines := runtime.NumGoroutine()
@@ -92,6 +92,38 @@ func Test_Poller_Poll(t *testing.T) {
 	})
 }
 
+func TestReleaseStaleBatches(t *testing.T) {
+	t.Run("it releases stale batches on each interval", func(t *testing.T) {
+		repo := test.NewMockRepository()
+		repo.SetStaleMessages(3)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go ReleaseStaleBatches(ctx, repo, "orders", time.Millisecond*10, nil)
+
+		time.Sleep(time.Millisecond * 55)
+		cancel()
+
+		if count := repo.ReleaseCallCount(); count < 2 {
+			t.Errorf("expected stale batches to be released several times, but they were released %d times", count)
+		}
+	})
+
+	t.Run("it waits for the interval after a repository error", func(t *testing.T) {
+		repo := test.NewMockRepository()
+		repo.ReturnErrors()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go ReleaseStaleBatches(ctx, repo, "orders", time.Second*200, nil)
+
+		time.Sleep(time.Millisecond * 100)
+		cancel()
+
+		if count := repo.ReleaseCallCount(); count != 1 {
+			t.Errorf("expected stale batches to be released once, but they were released %d times", count)
+		}
+	})
+}
+
 func readFromChannelUntilBatchReceived(b *outbox.Batch, ch chan *outbox.Batch, t *testing.T) {
 	select {
 	case actual := <-ch:
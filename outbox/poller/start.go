@@ -2,7 +2,12 @@ package poller
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
 
+	"github.com/Shopify/sarama"
 	nr "github.com/newrelic/go-agent/v3/newrelic"
 
 	"inviqa/kafka-outbox-relay/config"
@@ -12,7 +17,18 @@ import (
 	"inviqa/kafka-outbox-relay/outbox/processor"
 )
 
-func Start(ctx context.Context, cfg *config.Config, repo outbox.Repository, nrApp *nr.Application) func() {
+// clusterRouter routes each topic to the publisher for the Kafka cluster that
+// its messages are published to.
+type clusterRouter struct {
+	cfg  *config.Config
+	pubs map[string]processor.Publisher
+}
+
+func (r clusterRouter) Route(topic string) processor.Publisher {
+	return r.pubs[r.cfg.KafkaClusterFor(topic)]
+}
+
+func Start(ctx context.Context, cfg *config.Config, dbCfg config.Database, repo outbox.Repository, nrApp *nr.Application) func() {
 	logger := log.Logger.WithField("config", cfg)
 
 	// if polling has been disabled, we should
@@ -28,17 +44,252 @@ func Start(ctx context.Context, cfg *config.Config, repo outbox.Repository, nrAp
 	logger.Info("starting outbox relay polling")
 
 	batchCh := make(chan *outbox.Batch, 10)
-	pub := kafka.NewPublisher(cfg.KafkaHost, kafka.NewSaramaConfig(cfg.TLSEnable, cfg.TLSSkipVerifyPeer))
-	go New(repo, batchCh, nrApp).Poll(ctx, cfg.GetPollIntervalDurationInMs())
+	go New(repo, batchCh, dbCfg.Name, nrApp).Poll(ctx, cfg.GetPollIntervalDurationInMs())
+	go ReleaseStaleBatches(ctx, repo, dbCfg.Name, staleBatchReleaseInterval(cfg.StaleBatchTimeout), nrApp)
+
+	opts := newPublisherOptions(cfg, dbCfg)
+	var closers []io.Closer
+	var producers []*kafka.ReloadableProducer
+	newProducers := map[string]func(transactionalId string) sarama.SyncProducer{}
+	clusterPubs := map[string][]processor.Publisher{}
+	for _, cluster := range cfg.AllKafkaClusters() {
+		cluster := cluster
+		newProducer := func(transactionalId string) sarama.SyncProducer {
+			prod, err := kafka.NewReloadableProducer(producerFactory(cfg, cluster, transactionalId))
+			if err != nil {
+				log.Logger.Panicf("could not start kafka producer for the %s cluster: %s", cluster.Name, err)
+			}
+			producers = append(producers, prod)
+
+			return prod
+		}
+		newProducers[cluster.Name] = newProducer
+
+		pubs := newPublishers(cfg, dbCfg, opts, newProducer)
+		for _, pub := range pubs {
+			closers = append(closers, pub)
+		}
+		clusterPubs[cluster.Name] = pubs
+	}
 
-	proc := processor.NewBatchProcessor(repo, pub, nrApp)
-	for i := 0; i < cfg.WriteConcurrency; i++ {
+	var dl kafka.DeadLetterPublisher
+	if cfg.KafkaDeadLetterTopic != "" {
+		newProducer := newProducers[cfg.KafkaClusterFor(cfg.KafkaDeadLetterTopic)]
+		dl = kafka.NewDeadLetterPublisherWithProducer(newProducer(""), cfg.KafkaDeadLetterTopic)
+		closers = append(closers, dl)
+	}
+
+	watchCredentials(ctx, cfg, producers)
+
+	for i := 0; i < cfg.WriteConcurrencyFor(dbCfg); i++ {
+		rt := clusterRouter{cfg: cfg, pubs: map[string]processor.Publisher{}}
+		for name, pubs := range clusterPubs {
+			rt.pubs[name] = pubs[i%len(pubs)]
+		}
+
+		proc := processor.NewBatchProcessorWithRouter(repo, rt, nil, outbox.NewAttemptLimits(cfg), nrApp)
+		if cfg.KafkaDeadLetterTopic != "" {
+			proc = processor.NewBatchProcessorWithRouter(repo, rt, dl, outbox.NewAttemptLimits(cfg), nrApp)
+		}
 		go proc.ListenAndProcess(ctx, batchCh)
 	}
 
 	return func() {
-		if err := pub.Close(); err != nil {
-			log.Logger.WithError(err).Error("error closing kafka publisher during shutdown")
+		for _, c := range closers {
+			if err := c.Close(); err != nil {
+				log.Logger.WithError(err).Error("error closing kafka publisher during shutdown")
+			}
+		}
+	}
+}
+
+// staleBatchReleaseInterval returns how often to release stale batches, which
+// is half of the stale batch timeout, up to a minute, so that the messages in
+// a batch are released soon after it becomes stale.
+func staleBatchReleaseInterval(timeout time.Duration) time.Duration {
+	if interval := timeout / 2; interval < time.Minute {
+		return interval
+	}
+
+	return time.Minute
+}
+
+// newPublishers creates the Kafka publishers used by the batch processors. A
+// transactional producer can only have one transaction in flight at a time, so
+// when transactions are enabled each worker gets its own publisher, otherwise
+// a single publisher is shared between them.
+func newPublishers(cfg *config.Config, dbCfg config.Database, opts kafka.PublisherOptions, newProducer func(transactionalId string) sarama.SyncProducer) []processor.Publisher {
+	if !cfg.KafkaTransactional {
+		return []processor.Publisher{kafka.NewPublisherWithOptions(newProducer(""), opts)}
+	}
+
+	pubs := make([]processor.Publisher, cfg.WriteConcurrencyFor(dbCfg))
+	for i := range pubs {
+		id := kafka.TransactionalId(cfg.KafkaTransactionalIdPrefix, dbCfg.Name, i)
+		pubs[i] = kafka.NewTransactionalPublisherWithOptions(newProducer(id), opts)
+	}
+
+	return pubs
+}
+
+// newPublisherOptions creates the options used by every publisher to build the
+// messages that are published from the database's outbox.
+func newPublisherOptions(cfg *config.Config, dbCfg config.Database) kafka.PublisherOptions {
+	return kafka.PublisherOptions{
+		Topics:      newTopicRewriter(cfg),
+		Serializers: newSerializers(cfg),
+		Headers:     newRelayHeaders(cfg, dbCfg),
+	}
+}
+
+// newRelayHeaders creates the headers that are added to every message published
+// from the database's outbox, or nil if none have been enabled.
+func newRelayHeaders(cfg *config.Config, dbCfg config.Database) *kafka.RelayHeaders {
+	if len(cfg.KafkaRelayHeaders) == 0 {
+		return nil
+	}
+
+	h := &kafka.RelayHeaders{
+		DatabaseName: dbCfg.Name,
+		OutboxTable:  dbCfg.OutboxTable,
+	}
+	for _, name := range cfg.KafkaRelayHeaders {
+		switch name {
+		case config.RelayHeaderOutboxId:
+			h.OutboxId = true
+		case config.RelayHeaderDatabase:
+			h.Database = true
+		case config.RelayHeaderCreatedAt:
+			h.CreatedAt = true
+		case config.RelayHeaderBatchId:
+			h.BatchId = true
+		case config.RelayHeaderAttempt:
+			h.Attempt = true
+		case config.RelayHeaderMessageId:
+			h.MessageId = true
+		case config.RelayHeaderTraceContext:
+			h.TraceContext = true
+		}
+	}
+
+	return h
+}
+
+// newSerializers creates the serializers for the payloads of each topic, which
+// share a single schema registry client, and so its cache of schemas.
+func newSerializers(cfg *config.Config) kafka.Serializers {
+	if len(cfg.KafkaSerializers) == 0 {
+		return nil
+	}
+
+	reg := cfg.SchemaRegistry
+	registry := kafka.NewSchemaRegistryWithBasicAuth(reg.URL, reg.User, reg.Password, reg.CacheTTL)
+
+	serializers := make(kafka.Serializers, len(cfg.KafkaSerializers))
+	for i, s := range cfg.KafkaSerializers {
+		serializers[i].Pattern = s.Pattern
+		switch s.Value {
+		case config.SerializerAvro:
+			serializers[i].Serializer = kafka.NewAvroSerializer(registry)
+		case config.SerializerProtobuf:
+			serializers[i].Serializer = kafka.NewProtobufSerializer(registry)
+		case config.SerializerJSONSchema:
+			serializers[i].Serializer = kafka.NewJSONSchemaSerializer(registry)
+		}
+	}
+
+	return serializers
+}
+
+// newTopicRewriter creates the rewriter for the topics of the messages that are
+// published, which is nil if no topics are rewritten or blocked.
+func newTopicRewriter(cfg *config.Config) *kafka.TopicRewriter {
+	if !cfg.KafkaTopics.Enabled() {
+		return nil
+	}
+
+	rules := make([]kafka.TopicRewriteRule, len(cfg.KafkaTopics.Rewrites))
+	for i, r := range cfg.KafkaTopics.Rewrites {
+		// the patterns have already been validated by the config
+		rules[i] = kafka.TopicRewriteRule{Pattern: regexp.MustCompile(r.Pattern), Replacement: r.Replacement}
+	}
+
+	return &kafka.TopicRewriter{
+		Blocked: cfg.KafkaTopics.Blocked,
+		Mapping: cfg.KafkaTopics.Mapping,
+		Rules:   rules,
+		Prefix:  cfg.KafkaTopics.Prefix,
+		Suffix:  cfg.KafkaTopics.Suffix,
+	}
+}
+
+// producerFactory returns a func that creates a producer for the Kafka cluster,
+// which is a transactional producer if transactionalId is not empty. Any Kafka
+// credentials are read each time the func is called, so that the producer can
+// be recreated with new credentials after they have been rotated.
+func producerFactory(cfg *config.Config, cluster config.KafkaCluster, transactionalId string) func() (sarama.SyncProducer, error) {
+	return func() (sarama.SyncProducer, error) {
+		sc, err := newSaramaConfig(cfg, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		if transactionalId != "" {
+			kafka.EnableTransactions(sc, transactionalId)
+		}
+
+		return sarama.NewSyncProducer(cluster.Host, sc)
+	}
+}
+
+// newSaramaConfig creates the Sarama config for a producer connecting to the
+// Kafka cluster.
+func newSaramaConfig(cfg *config.Config, cluster config.KafkaCluster) (*sarama.Config, error) {
+	sc := kafka.NewSaramaConfig(cluster.TLSEnable, cluster.TLSSkipVerifyPeer)
+	if cluster.TLSEnable && cluster.TLSFiles.Configured() {
+		tlsCfg, err := cluster.TLSFiles.TLSConfig(cluster.TLSSkipVerifyPeer)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the TLS files for the %s Kafka cluster: %w", cluster.Name, err)
+		}
+		sc = kafka.NewSaramaConfigWithTLS(tlsCfg)
+	}
+	cfg.KafkaProducer.Apply(sc)
+	sc.Producer.Partitioner = kafka.NewOutboxPartitionerConstructor(cfg.KafkaPartitioning.Strategy)
+
+	if !cluster.SASL.Enabled() {
+		return sc, nil
+	}
+
+	user, pass, err := cluster.SASL.Credentials()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the SASL credentials for the %s Kafka cluster: %w", cluster.Name, err)
+	}
+	kafka.EnableSASL(sc, kafka.SASL{
+		Mechanism: sarama.SASLMechanism(cluster.SASL.Mechanism),
+		User:      user,
+		Password:  pass,
+		Token:     cluster.SASL.Token,
+	})
+
+	return sc, nil
+}
+
+// watchCredentials reloads the Kafka producers whenever the files containing
+// the Kafka credentials change. Each producer is only replaced once it has
+// finished publishing its current batch.
+func watchCredentials(ctx context.Context, cfg *config.Config, producers []*kafka.ReloadableProducer) {
+	reload := func() {
+		log.Logger.Info("reconnecting to Kafka with the new credentials")
+		for _, prod := range producers {
+			if err := prod.Reload(); err != nil {
+				log.Logger.WithError(err).Error("unable to reconnect to Kafka with the new credentials")
+			}
+		}
+	}
+
+	for _, cluster := range cfg.AllKafkaClusters() {
+		for _, file := range cluster.SASL.CredentialFiles() {
+			go config.WatchSecretFile(ctx, file, cfg.SecretsPollInterval, reload)
 		}
 	}
 }
@@ -0,0 +1,158 @@
+package poller
+
+import (
+	"testing"
+	"time"
+
+	"inviqa/kafka-outbox-relay/config"
+	"inviqa/kafka-outbox-relay/kafka"
+	"inviqa/kafka-outbox-relay/outbox/processor"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/go-test/deep"
+)
+
+func TestNewSaramaConfig(t *testing.T) {
+	sc, err := newSaramaConfig(&config.Config{}, config.KafkaCluster{
+		SASL: config.SASL{Mechanism: config.SASLScramSHA256, User: "relay", Password: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !sc.Net.SASL.Enable || sc.Net.SASL.Mechanism != sarama.SASLTypeSCRAMSHA256 {
+		t.Errorf("expected SCRAM-SHA-256 authentication to be enabled, but got %s", sc.Net.SASL.Mechanism)
+	}
+
+	if sc.Net.SASL.User != "relay" || sc.Net.SASL.Password != "secret" {
+		t.Errorf("expected the SASL credentials to be configured, but got '%s' and '%s'", sc.Net.SASL.User, sc.Net.SASL.Password)
+	}
+
+	sc, err = newSaramaConfig(&config.Config{}, config.KafkaCluster{
+		SASL: config.SASL{Mechanism: config.SASLOAuthBearer, OAuthToken: "token"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	token, err := sc.Net.SASL.TokenProvider.Token()
+	if err != nil || token.Token != "token" {
+		t.Errorf("expected the static OAUTHBEARER token, but got %v (%v)", token, err)
+	}
+
+	sc, err = newSaramaConfig(&config.Config{}, config.KafkaCluster{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if sc.Net.SASL.Enable {
+		t.Error("expected SASL to be disabled")
+	}
+
+	_, err = newSaramaConfig(&config.Config{}, config.KafkaCluster{
+		SASL: config.SASL{Mechanism: config.SASLPlain, User: "relay", PasswordFile: "/does/not/exist"},
+	})
+	if err == nil {
+		t.Error("expected an error when the SASL password file is missing, but got nil")
+	}
+}
+
+func TestStaleBatchReleaseInterval(t *testing.T) {
+	tests := map[time.Duration]time.Duration{
+		time.Second * 30: time.Second * 15,
+		time.Minute * 2:  time.Minute,
+		time.Minute * 10: time.Minute,
+	}
+
+	for timeout, exp := range tests {
+		if actual := staleBatchReleaseInterval(timeout); actual != exp {
+			t.Errorf("expected an interval of %s for a %s timeout, but got %s", exp, timeout, actual)
+		}
+	}
+}
+
+func TestClusterRouter_Route(t *testing.T) {
+	defaultPub := kafka.NewPublisherWithProducer(mocks.NewSyncProducer(t, nil))
+	compliancePub := kafka.NewPublisherWithProducer(mocks.NewSyncProducer(t, nil))
+
+	rt := clusterRouter{
+		cfg: &config.Config{
+			KafkaRoutes: config.TopicPatterns{{Pattern: "compliance.*", Value: "compliance"}},
+		},
+		pubs: map[string]processor.Publisher{
+			config.DefaultKafkaCluster: defaultPub,
+			"compliance":               compliancePub,
+		},
+	}
+
+	if rt.Route("compliance.checks") != compliancePub {
+		t.Error("expected the compliance topic to be routed to the compliance publisher")
+	}
+
+	if rt.Route("orders") != defaultPub {
+		t.Error("expected the orders topic to be routed to the default publisher")
+	}
+}
+
+func TestNewTopicRewriter(t *testing.T) {
+	if rw := newTopicRewriter(&config.Config{}); rw != nil {
+		t.Errorf("expected no topic rewriter when topics are not rewritten, but got %v", rw)
+	}
+
+	rw := newTopicRewriter(&config.Config{
+		KafkaTopics: config.TopicRewrite{
+			Prefix:   "staging.",
+			Rewrites: []config.TopicRewriteRule{{Pattern: `^orders\.v(\d+)$`, Replacement: "orders-v${1}"}},
+		},
+	})
+
+	if got, err := rw.Rewrite("orders.v2"); err != nil || got != "staging.orders-v2" {
+		t.Errorf("expected the topic to be rewritten to staging.orders-v2, but got %s (%v)", got, err)
+	}
+}
+
+func TestNewSerializers(t *testing.T) {
+	if s := newSerializers(&config.Config{}); s != nil {
+		t.Errorf("expected no serializers when none are configured, but got %v", s)
+	}
+
+	s := newSerializers(&config.Config{
+		KafkaSerializers: config.TopicPatterns{
+			{Pattern: "orders.*", Value: config.SerializerAvro},
+			{Pattern: "*", Value: config.SerializerJSON},
+		},
+		SchemaRegistry: config.SchemaRegistry{URL: "http://schema-registry:8081"},
+	})
+
+	if s.For("orders.created") == nil {
+		t.Error("expected a serializer for the orders.created topic")
+	}
+
+	if s.For("customers") != nil {
+		t.Error("expected the customers payloads to be published as they are")
+	}
+}
+
+func TestNewRelayHeaders(t *testing.T) {
+	dbCfg := config.Database{Name: "orders", OutboxTable: "kafka_outbox"}
+
+	if h := newRelayHeaders(&config.Config{}, dbCfg); h != nil {
+		t.Errorf("expected no relay headers when none are enabled, but got %v", h)
+	}
+
+	h := newRelayHeaders(&config.Config{
+		KafkaRelayHeaders: []string{config.RelayHeaderDatabase, config.RelayHeaderMessageId},
+	}, dbCfg)
+
+	exp := &kafka.RelayHeaders{
+		Database:     true,
+		MessageId:    true,
+		DatabaseName: "orders",
+		OutboxTable:  "kafka_outbox",
+	}
+
+	if diff := deep.Equal(exp, h); diff != nil {
+		t.Error(diff)
+	}
+}
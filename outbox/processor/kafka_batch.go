@@ -3,13 +3,18 @@ package processor
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"strings"
+	"time"
 
 	nr "github.com/newrelic/go-agent/v3/newrelic"
 
 	"inviqa/kafka-outbox-relay/log"
 	"inviqa/kafka-outbox-relay/newrelic"
 	"inviqa/kafka-outbox-relay/outbox"
+	"inviqa/kafka-outbox-relay/prometheus"
 
 	"github.com/sirupsen/logrus"
 )
@@ -18,12 +23,47 @@ type repository interface {
 	CommitBatch(ctx context.Context, batch *outbox.Batch)
 }
 
-type publisher interface {
+// Publisher publishes messages to Kafka.
+type Publisher interface {
 	io.Closer
 	PublishMessage(m *outbox.Message) error
 }
 
-func NewBatchProcessor(r repository, p publisher, nrApp *nr.Application) KafkaBatchProcessor {
+// Router returns the publisher for the Kafka cluster that messages for a topic
+// are published to. The publishers must be comparable, so that the messages for
+// the same publisher can be published together.
+type Router interface {
+	Route(topic string) Publisher
+}
+
+// batchPublisher is implemented by publishers that are able to send a whole
+// batch of messages to Kafka at once, setting the ErrorReason on each message
+// that could not be published.
+type batchPublisher interface {
+	PublishBatch(msgs []*outbox.Message) error
+}
+
+// transactionalPublisher is implemented by publishers that are able to wrap
+// each batch of messages in a Kafka transaction.
+type transactionalPublisher interface {
+	BeginTransaction() error
+	CommitTransaction() error
+	AbortTransaction() error
+}
+
+// traceContextPublisher is implemented by publishers that can add the
+// distributed trace headers of the transaction to each message.
+type traceContextPublisher interface {
+	TraceContext() bool
+}
+
+// deadLetterPublisher publishes messages that have exhausted all of their
+// publish attempts to a dead-letter topic.
+type deadLetterPublisher interface {
+	PublishDeadLetter(m *outbox.Message) error
+}
+
+func NewBatchProcessor(r repository, p Publisher, nrApp *nr.Application) KafkaBatchProcessor {
 	return KafkaBatchProcessor{
 		repo:      r,
 		publisher: p,
@@ -31,10 +71,40 @@ func NewBatchProcessor(r repository, p publisher, nrApp *nr.Application) KafkaBa
 	}
 }
 
+// NewBatchProcessorWithDeadLetterPublisher creates a KafkaBatchProcessor that
+// publishes any message that fails on its last attempt, within the limits, to a
+// dead-letter topic, using dl.
+func NewBatchProcessorWithDeadLetterPublisher(r repository, p Publisher, dl deadLetterPublisher, limits outbox.AttemptLimits, nrApp *nr.Application) KafkaBatchProcessor {
+	proc := NewBatchProcessor(r, p, nrApp)
+	proc.deadLetters = dl
+	proc.limits = limits
+
+	return proc
+}
+
+// NewBatchProcessorWithRouter creates a KafkaBatchProcessor that publishes
+// each message using the publisher that rt returns for its topic, so that the
+// messages in a batch can be published to different Kafka clusters. If dl is
+// not nil, then messages that fail on their last publish attempt are published
+// to a dead-letter topic, as with NewBatchProcessorWithDeadLetterPublisher. When
+// transactions are used, the messages for each publisher are published in a
+// separate transaction.
+func NewBatchProcessorWithRouter(r repository, rt Router, dl deadLetterPublisher, limits outbox.AttemptLimits, nrApp *nr.Application) KafkaBatchProcessor {
+	proc := NewBatchProcessor(r, nil, nrApp)
+	proc.router = rt
+	proc.deadLetters = dl
+	proc.limits = limits
+
+	return proc
+}
+
 type KafkaBatchProcessor struct {
-	repo      repository
-	publisher publisher
-	nrApp     *nr.Application
+	repo        repository
+	publisher   Publisher
+	router      Router
+	deadLetters deadLetterPublisher
+	limits      outbox.AttemptLimits
+	nrApp       *nr.Application
 }
 
 func (k KafkaBatchProcessor) ListenAndProcess(parent context.Context, batches <-chan *outbox.Batch) {
@@ -45,23 +115,30 @@ func (k KafkaBatchProcessor) ListenAndProcess(parent context.Context, batches <-
 				break
 			}
 
+			start := time.Now()
 			ctx, txn := newrelic.ContextWithTxn(parent, "processor: KafkaBatchProcessor.ListenAndProcess()", k.nrApp)
-			for _, msg := range b.Messages {
-				if msg.Topic == "" {
-					log.Logger.WithFields(logrus.Fields{"message_id": msg.Id}).Error("a message without a topic was detected in the outbox")
-					err := errors.New("this message has no topic")
-					msg.ErrorReason = err
-					txn.NoticeError(err)
-				} else {
-					log.Logger.WithFields(logrus.Fields{"message": msg}).Debug("sending message to Kafka publisher")
-					if err := k.publisher.PublishMessage(msg); err != nil {
-						log.Logger.WithError(err).Debug("error encountered whilst publishing a batch message to Kafka")
-						msg.ErrorReason = err
-						txn.NoticeError(err)
+			var th map[string]string
+			for _, rb := range k.routeBatch(b) {
+				if tc, ok := rb.publisher.(traceContextPublisher); ok && tc.TraceContext() {
+					if th == nil {
+						th = traceHeaders(txn)
+					}
+					for _, msg := range rb.batch.Messages {
+						msg.TraceHeaders = th
 					}
 				}
+				if tp, ok := rb.publisher.(transactionalPublisher); ok {
+					k.publishBatchInTransaction(rb.publisher, tp, rb.batch, txn)
+				} else {
+					k.publishBatch(rb.publisher, rb.batch, txn)
+				}
 			}
+			if k.deadLetters != nil {
+				k.publishDeadLetters(b, txn)
+			}
+			k.observeMessages(b)
 			k.repo.CommitBatch(ctx, b)
+			prometheus.ObserveBatch(len(b.Messages), time.Since(start))
 			txn.End()
 			break
 		case <-parent.Done():
@@ -69,3 +146,178 @@ func (k KafkaBatchProcessor) ListenAndProcess(parent context.Context, batches <-
 		}
 	}
 }
+
+// traceHeaders returns the distributed trace headers for the transaction, e.g.
+// traceparent and newrelic, with lower case keys, or nil if the transaction is
+// not being traced.
+func traceHeaders(txn *nr.Transaction) map[string]string {
+	hdrs := http.Header{}
+	txn.InsertDistributedTraceHeaders(hdrs)
+	if len(hdrs) == 0 {
+		return nil
+	}
+
+	th := make(map[string]string, len(hdrs))
+	for key := range hdrs {
+		th[strings.ToLower(key)] = hdrs.Get(key)
+	}
+
+	return th
+}
+
+// routedBatch holds the messages in a batch that are published using the same
+// publisher.
+type routedBatch struct {
+	publisher Publisher
+	batch     *outbox.Batch
+}
+
+// routeBatch splits the batch up by the publisher for each message's topic,
+// keeping the messages in the order that they were in the batch. Without a
+// router, every message is published using the same publisher.
+func (k KafkaBatchProcessor) routeBatch(b *outbox.Batch) []routedBatch {
+	if k.router == nil {
+		return []routedBatch{{publisher: k.publisher, batch: b}}
+	}
+
+	var routed []routedBatch
+	for _, msg := range b.Messages {
+		pub := k.router.Route(msg.Topic)
+
+		i := 0
+		for i < len(routed) && routed[i].publisher != pub {
+			i++
+		}
+		if i == len(routed) {
+			routed = append(routed, routedBatch{publisher: pub, batch: &outbox.Batch{Id: b.Id, Database: b.Database}})
+		}
+		routed[i].batch.Messages = append(routed[i].batch.Messages, msg)
+	}
+
+	return routed
+}
+
+// publishBatch publishes each message in the batch using pub, returning the
+// first error that was encountered whilst publishing, if any.
+func (k KafkaBatchProcessor) publishBatch(pub Publisher, b *outbox.Batch, txn *nr.Transaction) error {
+	msgs := make([]*outbox.Message, 0, len(b.Messages))
+	for _, msg := range b.Messages {
+		if msg.Topic == "" {
+			log.Logger.WithFields(logrus.Fields{"message_id": msg.Id}).Error("a message without a topic was detected in the outbox")
+			err := outbox.NewPermanentError(errors.New("this message has no topic"))
+			msg.ErrorReason = err
+			txn.NoticeError(err)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+
+	if bp, ok := pub.(batchPublisher); ok {
+		log.Logger.WithFields(logrus.Fields{"batch_id": b.Id.String(), "num_messages": len(msgs)}).Debug("sending batch to Kafka publisher")
+		err := bp.PublishBatch(msgs)
+		if err != nil {
+			log.Logger.WithError(err).Debug("error encountered whilst publishing a batch to Kafka")
+			txn.NoticeError(err)
+		}
+		return err
+	}
+
+	var publishErr error
+	for _, msg := range msgs {
+		log.Logger.WithFields(logrus.Fields{"message": msg}).Debug("sending message to Kafka publisher")
+		if err := pub.PublishMessage(msg); err != nil {
+			log.Logger.WithError(err).Debug("error encountered whilst publishing a batch message to Kafka")
+			msg.ErrorReason = err
+			txn.NoticeError(err)
+			if publishErr == nil {
+				publishErr = err
+			}
+		}
+	}
+
+	return publishErr
+}
+
+// publishBatchInTransaction publishes the batch inside a single Kafka transaction.
+// If any message fails to send, or the transaction cannot be committed, then the
+// transaction is aborted and every message in the batch is marked as errored, so
+// that the whole batch is retried.
+func (k KafkaBatchProcessor) publishBatchInTransaction(pub Publisher, tp transactionalPublisher, b *outbox.Batch, txn *nr.Transaction) {
+	if err := tp.BeginTransaction(); err != nil {
+		log.Logger.WithError(err).Error("unable to begin a Kafka transaction for the batch")
+		txn.NoticeError(err)
+		markBatchErrored(b, err)
+		return
+	}
+
+	err := k.publishBatch(pub, b, txn)
+	if err == nil {
+		err = tp.CommitTransaction()
+	}
+
+	if err == nil {
+		return
+	}
+
+	log.Logger.WithError(err).WithField("batch_id", b.Id.String()).Error("aborting the Kafka transaction for the batch")
+	txn.NoticeError(err)
+	if abortErr := tp.AbortTransaction(); abortErr != nil {
+		log.Logger.WithError(abortErr).Error("unable to abort the Kafka transaction for the batch")
+		txn.NoticeError(abortErr)
+	}
+	markBatchErrored(b, err)
+}
+
+// publishDeadLetters publishes every message in the batch that failed on its
+// last attempt, or with a permanent error, to the dead-letter topic.
+// Messages that could not be published to the dead-letter topic are left to be
+// marked as errored as usual.
+func (k KafkaBatchProcessor) publishDeadLetters(b *outbox.Batch, txn *nr.Transaction) {
+	for _, msg := range b.Messages {
+		if !k.limits.Exhausted(msg) {
+			continue
+		}
+
+		logger := log.Logger.WithFields(logrus.Fields{"message_id": msg.Id, "topic": msg.Topic})
+		if err := k.deadLetters.PublishDeadLetter(msg); err != nil {
+			logger.WithError(err).Error("unable to publish an errored message to the dead-letter topic")
+			txn.NoticeError(err)
+			continue
+		}
+
+		logger.Info("published an errored message to the dead-letter topic")
+		msg.DeadLettered = true
+	}
+}
+
+// markBatchErrored marks every message in the batch that has not already
+// failed as errored. These messages did nothing wrong, so they are retried
+// without consuming a publish attempt.
+func markBatchErrored(b *outbox.Batch, cause error) {
+	for _, msg := range b.Messages {
+		if msg.ErrorReason == nil {
+			msg.ErrorReason = outbox.NewRetriableError(fmt.Errorf("kafka transaction for the batch was aborted: %w", cause))
+		}
+	}
+}
+
+// observeMessages records the outcome of publishing each message in the batch,
+// including the time taken for each message that was published successfully to
+// reach Kafka, and whether each failed message has now errored out and will not
+// be published again.
+func (k KafkaBatchProcessor) observeMessages(b *outbox.Batch) {
+	for _, msg := range b.Messages {
+		if msg.ErrorReason == nil {
+			prometheus.ObserveMessagePublished(b.Database, msg.Topic)
+			if msg.CreatedAt.Valid {
+				prometheus.ObservePublishLag(time.Since(msg.CreatedAt.Time))
+			}
+			continue
+		}
+
+		prometheus.ObserveMessageFailed(b.Database, msg.Topic, string(outbox.ClassOf(msg.ErrorReason)))
+		if msg.DeadLettered || k.limits.Exhausted(msg) {
+			prometheus.ObserveMessageErrored(b.Database, msg.Topic)
+		}
+	}
+}
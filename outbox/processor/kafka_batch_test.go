@@ -2,10 +2,13 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"runtime"
 	"testing"
 	"time"
 
+	nr "github.com/newrelic/go-agent/v3/newrelic"
+
 	"inviqa/kafka-outbox-relay/outbox"
 	"inviqa/kafka-outbox-relay/outbox/processor/test"
 	otest "inviqa/kafka-outbox-relay/outbox/test"
@@ -260,3 +263,467 @@ func TestKafkaBatchProcessor_ListenAndProcessTerminatesWhenContextIsCancelled(t
 		time.Sleep(time.Millisecond * 10)
 	}
 }
+
+func TestKafkaBatchProcessor_ListenAndProcessInTransaction(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := otest.NewMockRepository()
+	pub := test.NewMockTransactionalPublisher()
+	ch := make(chan *outbox.Batch)
+
+	proc := NewBatchProcessor(repo, pub, nil)
+	go proc.ListenAndProcess(ctx, ch)
+
+	b1 := &outbox.Batch{
+		Id: uuid.New(),
+		Messages: []*outbox.Message{
+			{
+				Id:    1,
+				Topic: "foo",
+			},
+			{
+				Id:    2,
+				Topic: "foo",
+			},
+		},
+	}
+
+	ch <- b1
+
+	time.Sleep(time.Millisecond * 1)
+
+	if !pub.MessageWasPublished(b1.Messages[0]) || !pub.MessageWasPublished(b1.Messages[1]) {
+		t.Errorf("messages from the batch were not published")
+	}
+
+	if !repo.BatchWasCommitted(b1) {
+		t.Fatal("batch was not committed")
+	}
+
+	if began, committed, aborted := pub.TransactionCounts(); began != 1 || committed != 1 || aborted != 0 {
+		t.Errorf("expected 1 transaction to be started and committed, got began: %d, committed: %d, aborted: %d", began, committed, aborted)
+	}
+
+	for _, msg := range repo.GetCommittedBatch(b1).Messages {
+		if msg.ErrorReason != nil {
+			t.Errorf("unexpected error on message %d: %s", msg.Id, msg.ErrorReason)
+		}
+	}
+}
+
+func TestKafkaBatchProcessor_ListenAndProcessInTransactionWithPublishError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := otest.NewMockRepository()
+	pub := test.NewMockTransactionalPublisher()
+	ch := make(chan *outbox.Batch)
+
+	proc := NewBatchProcessor(repo, pub, nil)
+	go proc.ListenAndProcess(ctx, ch)
+
+	b1 := &outbox.Batch{
+		Id: uuid.New(),
+		Messages: []*outbox.Message{
+			{
+				Id:    1,
+				Topic: "foo",
+			},
+			{
+				Id:    2,
+				Topic: "foo",
+			},
+		},
+	}
+
+	pub.ErrorForMessage(b1.Messages[1])
+
+	ch <- b1
+
+	time.Sleep(time.Millisecond * 1)
+
+	if !repo.BatchWasCommitted(b1) {
+		t.Fatal("batch was not committed")
+	}
+
+	if began, committed, aborted := pub.TransactionCounts(); began != 1 || committed != 0 || aborted != 1 {
+		t.Errorf("expected 1 transaction to be started and aborted, got began: %d, committed: %d, aborted: %d", began, committed, aborted)
+	}
+
+	for _, msg := range repo.GetCommittedBatch(b1).Messages {
+		if msg.ErrorReason == nil {
+			t.Errorf("expected message %d to be marked with an error", msg.Id)
+		}
+	}
+
+	if class := outbox.ClassOf(b1.Messages[0].ErrorReason); class != outbox.Retriable {
+		t.Errorf("expected the message aborted with the transaction to have a retriable error, but got %s", class)
+	}
+}
+
+func TestKafkaBatchProcessor_ListenAndProcessInTransactionWithCommitError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := otest.NewMockRepository()
+	pub := test.NewMockTransactionalPublisher()
+	pub.ErrorOnCommit()
+	ch := make(chan *outbox.Batch)
+
+	proc := NewBatchProcessor(repo, pub, nil)
+	go proc.ListenAndProcess(ctx, ch)
+
+	b1 := &outbox.Batch{
+		Id: uuid.New(),
+		Messages: []*outbox.Message{
+			{
+				Id:    1,
+				Topic: "foo",
+			},
+		},
+	}
+
+	ch <- b1
+
+	time.Sleep(time.Millisecond * 1)
+
+	if !repo.BatchWasCommitted(b1) {
+		t.Fatal("batch was not committed")
+	}
+
+	if _, _, aborted := pub.TransactionCounts(); aborted != 1 {
+		t.Errorf("expected the transaction to be aborted after a failed commit")
+	}
+
+	if repo.GetCommittedBatch(b1).Messages[0].ErrorReason == nil {
+		t.Error("expected the message to be marked with an error")
+	}
+}
+
+func TestKafkaBatchProcessor_ListenAndProcessWithBatchPublisher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := otest.NewMockRepository()
+	pub := test.NewMockBatchPublisher()
+	ch := make(chan *outbox.Batch)
+
+	proc := NewBatchProcessor(repo, pub, nil)
+	go proc.ListenAndProcess(ctx, ch)
+
+	b1 := &outbox.Batch{
+		Id: uuid.New(),
+		Messages: []*outbox.Message{
+			{
+				Id:    1,
+				Topic: "foo",
+			},
+			{
+				Id: 2,
+			},
+			{
+				Id:    3,
+				Topic: "foo",
+			},
+		},
+	}
+
+	pub.ErrorForMessage(b1.Messages[2])
+
+	ch <- b1
+
+	time.Sleep(time.Millisecond * 1)
+
+	if pub.BatchesPublished() != 1 {
+		t.Errorf("expected the messages to be published in a single batch, but got %d batches", pub.BatchesPublished())
+	}
+
+	if !pub.MessageWasPublished(b1.Messages[0]) {
+		t.Error("message with a topic was not published to kafka as expected")
+	}
+
+	if pub.MessageWasPublished(b1.Messages[1]) {
+		t.Error("a message without a topic was published to kafka")
+	}
+
+	if !repo.BatchWasCommitted(b1) {
+		t.Fatal("batch was not committed")
+	}
+
+	committed := repo.GetCommittedBatch(b1)
+	if committed.Messages[0].ErrorReason != nil {
+		t.Errorf("unexpected error on the first message: %s", committed.Messages[0].ErrorReason)
+	}
+
+	if committed.Messages[1].ErrorReason == nil || committed.Messages[2].ErrorReason == nil {
+		t.Error("expected the failed messages to be marked with an error")
+	}
+}
+
+func TestNewBatchProcessorWithDeadLetterPublisher(t *testing.T) {
+	deep.CompareUnexportedFields = true
+	defer func() {
+		deep.CompareUnexportedFields = false
+	}()
+
+	repo := otest.NewMockRepository()
+	pub := test.NewMockPublisher()
+	dl := test.NewMockDeadLetterPublisher()
+
+	exp := KafkaBatchProcessor{
+		repo:        repo,
+		publisher:   pub,
+		deadLetters: dl,
+		limits:      outbox.AttemptLimits{Publish: 3, Retry: 5},
+		nrApp:       nil,
+	}
+
+	if diff := deep.Equal(exp, NewBatchProcessorWithDeadLetterPublisher(repo, pub, dl, outbox.AttemptLimits{Publish: 3, Retry: 5}, nil)); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestKafkaBatchProcessor_ListenAndProcessWithDeadLetters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := otest.NewMockRepository()
+	pub := test.NewMockPublisher()
+	dl := test.NewMockDeadLetterPublisher()
+	ch := make(chan *outbox.Batch)
+
+	proc := NewBatchProcessorWithDeadLetterPublisher(repo, pub, dl, outbox.AttemptLimits{Publish: 3, Retry: 5}, nil)
+	go proc.ListenAndProcess(ctx, ch)
+
+	b1 := &outbox.Batch{
+		Id: uuid.New(),
+		Messages: []*outbox.Message{
+			{
+				Id:           1,
+				Topic:        "foo",
+				PushAttempts: 2,
+			},
+			{
+				Id:           2,
+				Topic:        "foo",
+				PushAttempts: 1,
+			},
+			{
+				Id:           3,
+				Topic:        "foo",
+				PushAttempts: 2,
+			},
+		},
+	}
+
+	pub.ErrorForMessage(b1.Messages[0])
+	pub.ErrorForMessage(b1.Messages[1])
+
+	ch <- b1
+
+	time.Sleep(time.Millisecond * 1)
+
+	if !repo.BatchWasCommitted(b1) {
+		t.Fatal("batch was not committed")
+	}
+
+	committed := repo.GetCommittedBatch(b1)
+	if !dl.MessageWasDeadLettered(b1.Messages[0]) || !committed.Messages[0].DeadLettered {
+		t.Error("expected the message on its last attempt to be dead-lettered")
+	}
+
+	if dl.MessageWasDeadLettered(b1.Messages[1]) || committed.Messages[1].DeadLettered {
+		t.Error("a message with remaining attempts was dead-lettered")
+	}
+
+	if dl.MessageWasDeadLettered(b1.Messages[2]) || committed.Messages[2].DeadLettered {
+		t.Error("a successfully published message was dead-lettered")
+	}
+}
+
+func TestKafkaBatchProcessor_ListenAndProcessWithDeadLetterError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := otest.NewMockRepository()
+	pub := test.NewMockPublisher()
+	dl := test.NewMockDeadLetterPublisher()
+	dl.ReturnErrors()
+	ch := make(chan *outbox.Batch)
+
+	proc := NewBatchProcessorWithDeadLetterPublisher(repo, pub, dl, outbox.AttemptLimits{Publish: 3, Retry: 5}, nil)
+	go proc.ListenAndProcess(ctx, ch)
+
+	b1 := &outbox.Batch{
+		Id: uuid.New(),
+		Messages: []*outbox.Message{
+			{
+				Id:           1,
+				Topic:        "foo",
+				PushAttempts: 2,
+			},
+		},
+	}
+
+	pub.ErrorForMessage(b1.Messages[0])
+
+	ch <- b1
+
+	time.Sleep(time.Millisecond * 1)
+
+	if !repo.BatchWasCommitted(b1) {
+		t.Fatal("batch was not committed")
+	}
+
+	committed := repo.GetCommittedBatch(b1)
+	if committed.Messages[0].DeadLettered {
+		t.Error("expected the message not to be marked as dead-lettered")
+	}
+
+	if committed.Messages[0].ErrorReason == nil {
+		t.Error("expected the message to be marked with an error")
+	}
+}
+
+func TestKafkaBatchProcessor_ListenAndProcessWithClassifiedErrorsAndDeadLetters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := otest.NewMockRepository()
+	pub := test.NewMockPublisher()
+	dl := test.NewMockDeadLetterPublisher()
+	ch := make(chan *outbox.Batch)
+
+	proc := NewBatchProcessorWithDeadLetterPublisher(repo, pub, dl, outbox.AttemptLimits{Publish: 3, Retry: 5}, nil)
+	go proc.ListenAndProcess(ctx, ch)
+
+	b1 := &outbox.Batch{
+		Id: uuid.New(),
+		Messages: []*outbox.Message{
+			{
+				Id:           1,
+				Topic:        "foo",
+				PushAttempts: 0,
+			},
+			{
+				Id:           2,
+				Topic:        "foo",
+				PushAttempts: 2,
+			},
+			{
+				Id:           3,
+				PushAttempts: 0,
+			},
+			{
+				Id:            4,
+				Topic:         "foo",
+				RetryAttempts: 4,
+			},
+		},
+	}
+
+	pub.ReturnErrorForMessage(b1.Messages[0], outbox.NewPermanentError(errors.New("too large")))
+	pub.ReturnErrorForMessage(b1.Messages[1], outbox.NewRetriableError(errors.New("out of brokers")))
+	pub.ReturnErrorForMessage(b1.Messages[3], outbox.NewRetriableError(errors.New("out of brokers")))
+
+	ch <- b1
+
+	time.Sleep(time.Millisecond * 1)
+
+	if !repo.BatchWasCommitted(b1) {
+		t.Fatal("batch was not committed")
+	}
+
+	if !dl.MessageWasDeadLettered(b1.Messages[0]) {
+		t.Error("expected the message with a permanent error to be dead-lettered on its first attempt")
+	}
+
+	if dl.MessageWasDeadLettered(b1.Messages[1]) {
+		t.Error("a message with a retriable error and remaining retries was dead-lettered")
+	}
+
+	if !dl.MessageWasDeadLettered(b1.Messages[3]) {
+		t.Error("expected the message with a retriable error on its last retry to be dead-lettered")
+	}
+
+	if !dl.MessageWasDeadLettered(b1.Messages[2]) {
+		t.Error("expected the message without a topic to be dead-lettered on its first attempt")
+	}
+}
+
+// topicRouter routes each topic to its publisher, or to the publisher for the
+// empty topic.
+type topicRouter map[string]Publisher
+
+func (r topicRouter) Route(topic string) Publisher {
+	if pub, ok := r[topic]; ok {
+		return pub
+	}
+
+	return r[""]
+}
+
+func TestKafkaBatchProcessor_ListenAndProcessWithRouter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := otest.NewMockRepository()
+	defaultPub := test.NewMockTransactionalPublisher()
+	compliancePub := test.NewMockTransactionalPublisher()
+	ch := make(chan *outbox.Batch)
+
+	proc := NewBatchProcessorWithRouter(repo, topicRouter{"": defaultPub, "compliance": compliancePub}, nil, outbox.AttemptLimits{}, nil)
+	go proc.ListenAndProcess(ctx, ch)
+
+	b1 := &outbox.Batch{
+		Id: uuid.New(),
+		Messages: []*outbox.Message{
+			{
+				Id:    1,
+				Topic: "foo",
+			},
+			{
+				Id:    2,
+				Topic: "compliance",
+			},
+			{
+				Id:    3,
+				Topic: "foo",
+			},
+		},
+	}
+
+	compliancePub.ErrorForMessage(b1.Messages[1])
+
+	ch <- b1
+
+	time.Sleep(time.Millisecond * 1)
+
+	if !repo.BatchWasCommitted(b1) {
+		t.Fatal("batch was not committed")
+	}
+
+	if !defaultPub.MessageWasPublished(b1.Messages[0]) || !defaultPub.MessageWasPublished(b1.Messages[2]) {
+		t.Error("expected the foo messages to be published by the default publisher")
+	}
+
+	if defaultPub.MessageWasPublished(b1.Messages[1]) {
+		t.Error("the compliance message was published by the default publisher")
+	}
+
+	if began, committed, aborted := defaultPub.TransactionCounts(); began != 1 || committed != 1 || aborted != 0 {
+		t.Errorf("expected 1 transaction to be started and committed for the default publisher, got began: %d, committed: %d, aborted: %d", began, committed, aborted)
+	}
+
+	if began, committed, aborted := compliancePub.TransactionCounts(); began != 1 || committed != 0 || aborted != 1 {
+		t.Errorf("expected 1 transaction to be started and aborted for the compliance publisher, got began: %d, committed: %d, aborted: %d", began, committed, aborted)
+	}
+
+	committed := repo.GetCommittedBatch(b1)
+	if committed.Messages[0].ErrorReason != nil || committed.Messages[2].ErrorReason != nil {
+		t.Error("the messages published to the default cluster were marked as errored")
+	}
+
+	if committed.Messages[1].ErrorReason == nil {
+		t.Error("expected the compliance message to be marked as errored")
+	}
+}
+
+func TestTraceHeaders(t *testing.T) {
+	if th := traceHeaders(&nr.Transaction{}); th != nil {
+		t.Errorf("expected no trace headers for a transaction that is not being traced, but got %v", th)
+	}
+}
@@ -50,6 +50,143 @@ func (p *mockPublisher) ErrorForMessage(m *outbox.Message) {
 	p.errors[m] = errors.New("foo")
 }
 
+func (p *mockPublisher) ReturnErrorForMessage(m *outbox.Message, err error) {
+	p.Lock()
+	defer p.Unlock()
+	p.errors[m] = err
+}
+
 func (p *mockPublisher) Close() error {
 	return nil
 }
+
+type mockTransactionalPublisher struct {
+	*mockPublisher
+	began     int
+	committed int
+	aborted   int
+	commitErr error
+}
+
+func NewMockTransactionalPublisher() *mockTransactionalPublisher {
+	return &mockTransactionalPublisher{
+		mockPublisher: NewMockPublisher(),
+	}
+}
+
+func (p *mockTransactionalPublisher) BeginTransaction() error {
+	p.Lock()
+	defer p.Unlock()
+	p.began++
+
+	return nil
+}
+
+func (p *mockTransactionalPublisher) CommitTransaction() error {
+	p.Lock()
+	defer p.Unlock()
+	if p.commitErr != nil {
+		return p.commitErr
+	}
+	p.committed++
+
+	return nil
+}
+
+func (p *mockTransactionalPublisher) AbortTransaction() error {
+	p.Lock()
+	defer p.Unlock()
+	p.aborted++
+
+	return nil
+}
+
+func (p *mockTransactionalPublisher) ErrorOnCommit() {
+	p.Lock()
+	defer p.Unlock()
+	p.commitErr = errors.New("commit failed")
+}
+
+func (p *mockTransactionalPublisher) TransactionCounts() (began, committed, aborted int) {
+	p.RLock()
+	defer p.RUnlock()
+
+	return p.began, p.committed, p.aborted
+}
+
+type mockBatchPublisher struct {
+	*mockPublisher
+	batchesPublished int
+}
+
+func NewMockBatchPublisher() *mockBatchPublisher {
+	return &mockBatchPublisher{
+		mockPublisher: NewMockPublisher(),
+	}
+}
+
+func (p *mockBatchPublisher) PublishBatch(msgs []*outbox.Message) error {
+	p.Lock()
+	defer p.Unlock()
+	p.batchesPublished++
+
+	var firstErr error
+	for _, m := range msgs {
+		if err, ok := p.errors[m]; ok {
+			m.ErrorReason = err
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		p.publishedMessages = append(p.publishedMessages, m)
+	}
+
+	return firstErr
+}
+
+func (p *mockBatchPublisher) BatchesPublished() int {
+	p.RLock()
+	defer p.RUnlock()
+
+	return p.batchesPublished
+}
+
+type mockDeadLetterPublisher struct {
+	sync.RWMutex
+	deadLetters []*outbox.Message
+	returnError bool
+}
+
+func NewMockDeadLetterPublisher() *mockDeadLetterPublisher {
+	return &mockDeadLetterPublisher{}
+}
+
+func (p *mockDeadLetterPublisher) PublishDeadLetter(m *outbox.Message) error {
+	p.Lock()
+	defer p.Unlock()
+	if p.returnError {
+		return errors.New("oops")
+	}
+	p.deadLetters = append(p.deadLetters, m)
+
+	return nil
+}
+
+func (p *mockDeadLetterPublisher) MessageWasDeadLettered(exp *outbox.Message) bool {
+	p.RLock()
+	defer p.RUnlock()
+	for _, m := range p.deadLetters {
+		if m == exp {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *mockDeadLetterPublisher) ReturnErrors() {
+	p.Lock()
+	defer p.Unlock()
+	p.returnError = true
+}
@@ -20,7 +20,7 @@ import (
 var (
 	ErrNoEvents = errors.New("no events in the batch")
 
-	columns = []string{"id", "batch_id", "push_started_at", "push_completed_at", "topic", "payload_json", "payload_headers", "push_attempts", "key", "partition_key"}
+	columns = []string{"id", "batch_id", "push_started_at", "push_completed_at", "topic", "payload_json", "payload_headers", "push_attempts", "key", "partition_key", "partition", "tombstone", "retry_attempts", "created_at"}
 )
 
 const (
@@ -36,10 +36,17 @@ type queryProvider interface {
 	BatchCreationSql(batchSize int) string
 	BatchFetchSql() string
 	MessageErroredUpdateSql(maxPushAttempts int) string
+	MessageRetryUpdateSql(maxRetryAttempts int) string
+	MessagePermanentlyErroredUpdateSql() string
+	MessageDeadLetteredUpdateSql() string
 	MessagesSuccessUpdateSql(idCount int) string
 	DeletePublishedMessagesSql() string
-	GetQueueSizeSql() string
-	GetTotalSizeSql() string
+	GetQueueSizeByTopicSql() string
+	GetTotalSizeByTopicSql() string
+	OldestUnpublishedAgeSql() string
+	StaleBatchReleaseSql() string
+	CountErroredSql(f s.ErroredFilter) (string, []any)
+	RequeueErroredSql(f s.ErroredFilter) (string, []any)
 }
 
 type Repository struct {
@@ -78,11 +85,9 @@ func (r Repository) GetBatch(ctx context.Context) (*Batch, error) {
 	defer newrelic.FromContext(ctx).StartSegment("outbox: Repository.GetBatch()").End()
 
 	batchId := uuid.New()
-	stale := time.Now().In(time.UTC).Add(time.Duration(-10) * time.Minute) // TODO: make this configurable??
+	upSql := r.queryProvider.BatchCreationSql(r.cfg.BatchSizeFor(r.dbCfg))
 
-	upSql := r.queryProvider.BatchCreationSql(r.cfg.BatchSize)
-
-	res, err := r.execContext(ctx, upSql, Update, batchId, stale, 0)
+	res, err := r.execContext(ctx, upSql, Update, batchId, 0)
 	if err != nil {
 		return nil, errors.Errorf("outbox: error creating a batch of events in repository: %s", err)
 	}
@@ -100,12 +105,13 @@ func (r Repository) GetBatch(ctx context.Context) (*Batch, error) {
 
 	batch := &Batch{
 		Id:       batchId,
+		Database: r.dbCfg.Name,
 		Messages: []*Message{},
 	}
 
 	for rows.Next() {
 		msg := &Message{}
-		err := rows.Scan(&msg.Id, &msg.BatchId, &msg.PushStartedAt, &msg.PushCompletedAt, &msg.Topic, &msg.PayloadJson, &msg.PayloadHeaders, &msg.PushAttempts, &msg.Key, &msg.PartitionKey)
+		err := rows.Scan(&msg.Id, &msg.BatchId, &msg.PushStartedAt, &msg.PushCompletedAt, &msg.Topic, &msg.PayloadJson, &msg.PayloadHeaders, &msg.PushAttempts, &msg.Key, &msg.PartitionKey, &msg.Partition, &msg.Tombstone, &msg.RetryAttempts, &msg.CreatedAt)
 		if err != nil {
 			return nil, errors.Errorf("outbox: error scanning event result into memory in repository: %s", err)
 		}
@@ -169,37 +175,144 @@ func (r Repository) DeletePublished(ctx context.Context, olderThan time.Time) (i
 	return res.RowsAffected()
 }
 
-func (r Repository) GetQueueSize(ctx context.Context) (uint, error) {
-	q := r.queryProvider.GetQueueSizeSql()
+// GetQueueSizeByTopic returns the number of unpublished messages for each
+// topic. Topics without any unpublished messages are not included.
+func (r Repository) GetQueueSizeByTopic(ctx context.Context) (map[string]uint, error) {
+	return r.countByTopic(ctx, r.queryProvider.GetQueueSizeByTopicSql())
+}
+
+// GetTotalSizeByTopic returns the number of messages for each topic.
+func (r Repository) GetTotalSizeByTopic(ctx context.Context) (map[string]uint, error) {
+	return r.countByTopic(ctx, r.queryProvider.GetTotalSizeByTopicSql())
+}
+
+// GetOldestUnpublishedAge returns how long ago the oldest message that is still
+// waiting to be published was created, or zero if there are no such messages.
+// Errored messages are not included, as they will not be published again.
+func (r Repository) GetOldestUnpublishedAge(ctx context.Context) (time.Duration, error) {
+	q := r.queryProvider.OldestUnpublishedAgeSql()
 	res := r.queryRowContext(ctx, q)
 
-	var count uint
-	err := res.Scan(&count)
+	var micros sql.NullInt64
+	if err := res.Scan(&micros); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(micros.Int64) * time.Microsecond, nil
+}
+
+// GetErroredCount returns the number of messages that have errored, and will
+// not be published again unless they are requeued.
+func (r Repository) GetErroredCount(ctx context.Context) (uint, error) {
+	count, err := r.CountErrored(ctx, s.ErroredFilter{})
 	if err != nil {
 		return 0, err
 	}
 
+	return uint(count), nil
+}
+
+// CountErrored returns the number of errored, unpublished messages that match
+// the filter.
+func (r Repository) CountErrored(ctx context.Context, f s.ErroredFilter) (int64, error) {
+	defer newrelic.FromContext(ctx).StartSegment("outbox: Repository.CountErrored()").End()
+
+	q, args := r.queryProvider.CountErroredSql(f)
+	res := r.queryRowContext(ctx, q, args...)
+
+	var count int64
+	if err := res.Scan(&count); err != nil {
+		return 0, err
+	}
+
 	return count, nil
 }
 
-func (r Repository) GetTotalSize(ctx context.Context) (uint, error) {
-	q := r.queryProvider.GetTotalSizeSql()
-	res := r.queryRowContext(ctx, q)
+// RequeueErrored resets errored, unpublished messages that match the filter so
+// that they are picked up again by the poller, with a fresh set of publish
+// attempts. It returns the number of messages that were requeued.
+func (r Repository) RequeueErrored(ctx context.Context, f s.ErroredFilter) (int64, error) {
+	defer newrelic.FromContext(ctx).StartSegment("outbox: Repository.RequeueErrored()").End()
+
+	q, args := r.queryProvider.RequeueErroredSql(f)
+
+	log.Logger.WithFields(logrus.Fields{"query": q, "args": args}).Debug("requeueing errored messages")
 
-	var count uint
-	err := res.Scan(&count)
+	res, err := r.execContext(ctx, q, Update, args...)
 	if err != nil {
 		return 0, err
 	}
 
+	return res.RowsAffected()
+}
+
+// ReleaseStaleBatches releases messages from any batches that were started
+// but not completed within the stale batch timeout, e.g. because the relay
+// processing them died, so that they can be picked up again. It returns the
+// number of messages released.
+func (r Repository) ReleaseStaleBatches(ctx context.Context) (int64, error) {
+	defer newrelic.FromContext(ctx).StartSegment("outbox: Repository.ReleaseStaleBatches()").End()
+
+	stale := time.Now().In(time.UTC).Add(-r.cfg.StaleBatchTimeout)
+
+	res, err := r.execContext(ctx, r.queryProvider.StaleBatchReleaseSql(), Update, stale, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	// the drivers we use never return an error value here
+	count, _ := res.RowsAffected()
+	if count > 0 {
+		log.Logger.WithField("count", count).Warn("released messages from stale outbox batches")
+	}
+
 	return count, nil
 }
 
+func (r Repository) countByTopic(ctx context.Context, q string) (map[string]uint, error) {
+	rows, err := r.queryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]uint{}
+	for rows.Next() {
+		var topic string
+		var count uint
+		if err := rows.Scan(&topic, &count); err != nil {
+			return nil, err
+		}
+		counts[topic] = count
+	}
+
+	return counts, rows.Err()
+}
+
 func (r Repository) updateErroredMessage(ctx context.Context, tx *sql.Tx, msg *Message) {
-	q := r.queryProvider.MessageErroredUpdateSql(r.cfg.KafkaPublishAttempts)
-	_, err := r.execContextWithTx(ctx, tx, q, Update, msg.ErrorReason.Error(), msg.Id)
+	class := ClassOf(msg.ErrorReason)
+	args := []any{msg.ErrorReason.Error(), string(class), msg.Id}
+
+	var q string
+	switch {
+	case msg.DeadLettered:
+		q = r.queryProvider.MessageDeadLetteredUpdateSql()
+	case class == Permanent:
+		// there is no point retrying, so the message errors straight away
+		q = r.queryProvider.MessagePermanentlyErroredUpdateSql()
+	case class == Retriable:
+		// retries have their own count, which the backoff grows with
+		delay := retryDelay(msg.RetryAttempts+1, r.cfg.KafkaRetryBaseDelay, r.cfg.KafkaRetryMaxDelay)
+		q = r.queryProvider.MessageRetryUpdateSql(r.cfg.KafkaRetryAttempts)
+		args = []any{msg.ErrorReason.Error(), string(class), delay.Microseconds(), msg.Id}
+	default:
+		delay := retryDelay(msg.PushAttempts+1, r.cfg.KafkaRetryBaseDelay, r.cfg.KafkaRetryMaxDelay)
+		q = r.queryProvider.MessageErroredUpdateSql(r.cfg.KafkaPublishAttempts)
+		args = []any{msg.ErrorReason.Error(), string(class), delay.Microseconds(), msg.Id}
+	}
+	_, err := r.execContextWithTx(ctx, tx, q, Update, args...)
 
-	log.Logger.WithFields(logrus.Fields{"query": q, "error_reason": msg.ErrorReason, "id": msg.Id}).Debug("updating errored message")
+	log.Logger.WithFields(logrus.Fields{"query": q, "error_reason": msg.ErrorReason, "error_class": class, "id": msg.Id}).Debug("updating errored message")
 
 	if err != nil {
 		log.Logger.Errorf("error occurred updating the outbox message with ID %d: %s", msg.Id, err)
@@ -220,12 +333,12 @@ func newQueryProvider(d config.DbDriver, table string, columns []string) queryPr
 	switch true {
 	case d.Postgres():
 		return &s.PostgresQueryProvider{
-			Table:   table,
+			Table:   d.QuoteIdentifier(table),
 			Columns: columns,
 		}
 	case d.MySQL():
 		return &s.MysqlQueryProvider{
-			Table:   table,
+			Table:   d.QuoteIdentifier(table),
 			Columns: columns,
 		}
 	}
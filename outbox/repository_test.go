@@ -3,6 +3,7 @@ package outbox
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"testing"
@@ -38,7 +39,7 @@ func TestNewRepository(t *testing.T) {
 				OutboxTable: "outbox_table",
 				Driver:      config.MySQL,
 			},
-			expQueryProvider: &s.MysqlQueryProvider{Table: "outbox_table", Columns: columns},
+			expQueryProvider: &s.MysqlQueryProvider{Table: "`outbox_table`", Columns: columns},
 		},
 		{
 			name: "postgres query provider",
@@ -46,7 +47,7 @@ func TestNewRepository(t *testing.T) {
 				OutboxTable: "outbox_table",
 				Driver:      config.Postgres,
 			},
-			expQueryProvider: &s.PostgresQueryProvider{Table: "outbox_table", Columns: columns},
+			expQueryProvider: &s.PostgresQueryProvider{Table: `"outbox_table"`, Columns: columns},
 		},
 	}
 
@@ -100,13 +101,13 @@ func TestRepository_GetBatch(t *testing.T) {
 	defer db.Close()
 	now := time.Now()
 	now2 := now.Add(time.Second * 1)
-	repo := NewRepositoryWithQueryProvider(db, &config.Config{BatchSize: 100}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+	repo := NewRepositoryWithQueryProvider(db, &config.Config{BatchSize: 100}, config.Database{Driver: config.MySQL, Name: "orders"}, &mockQueryProvider{})
 	ctx := context.Background()
 
 	msgBatchId := uuid.MustParse("f58e7c8a-e0d2-47fb-8111-eb0ae02ea21e")
 	rows := sqlmock.NewRows(columns).
-		AddRow(123, msgBatchId, now, now2, "event.product", "foo", "{}", 0, "key-0", "partition-key-0").
-		AddRow(124, msgBatchId, now, now2, "event.price", "bar", "{}", 1, "key-1", "partition-key-1")
+		AddRow(123, msgBatchId, now, now2, "event.product", "foo", "{}", 0, "key-0", "partition-key-0", nil, 0, 0, now).
+		AddRow(124, msgBatchId, now, now2, "event.price", "bar", "{}", 1, "key-1", "partition-key-1", 3, 1, 4, now)
 
 	t.Run("it gets a batch of events", func(t *testing.T) {
 		mock.ExpectExec(`UPDATE outbox LIMIT 100`).
@@ -129,6 +130,7 @@ func TestRepository_GetBatch(t *testing.T) {
 
 		exp := getExpectedMessageBatchForTest(msgBatchId, now, now2)
 		exp.Id = got.Id
+		exp.Database = "orders"
 		if diff := deep.Equal(exp, got); diff != nil {
 			t.Error(diff)
 		}
@@ -188,11 +190,15 @@ func TestRepository_CommitBatch(t *testing.T) {
 	batchId := uuid.New()
 	batch := createMockBatch(batchId)
 
-	repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+	defer func(orig func(time.Duration) time.Duration) { jitter = orig }(jitter)
+	jitter = func(time.Duration) time.Duration { return 0 }
+
+	cfg := &config.Config{KafkaRetryBaseDelay: time.Second * 2, KafkaRetryMaxDelay: time.Second * 2}
+	repo := NewRepositoryWithQueryProvider(db, cfg, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
 
 	mock.ExpectBegin()
 	mock.ExpectExec("UPDATE outbox SET error_reason =.* WHERE id =.*").
-		WithArgs(batch.Messages[1].ErrorReason.Error(), batch.Messages[1].Id).
+		WithArgs(batch.Messages[1].ErrorReason.Error(), "unclassified", int64(time.Second/time.Microsecond), batch.Messages[1].Id).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	mock.ExpectExec("UPDATE outbox SET push_completed_at =.* WHERE id IN.*").
@@ -208,6 +214,106 @@ func TestRepository_CommitBatch(t *testing.T) {
 	}
 }
 
+func TestRepository_CommitBatchWithDeadLetteredMessage(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	ctx := context.Background()
+
+	batchId := uuid.New()
+	batch := createMockBatch(batchId)
+	batch.Messages[1].DeadLettered = true
+
+	repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE outbox SET error_reason =.*, dead_lettered_at = NOW\\(\\) WHERE id =.*").
+		WithArgs(batch.Messages[1].ErrorReason.Error(), "unclassified", batch.Messages[1].Id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec("UPDATE outbox SET push_completed_at =.* WHERE id IN.*").
+		WithArgs(batch.Messages[0].Id, batch.Messages[2].Id).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	mock.ExpectCommit()
+
+	repo.CommitBatch(ctx, batch)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("some SQL expectations were not met: %s", err)
+	}
+}
+
+func TestRepository_CommitBatchWithClassifiedErrors(t *testing.T) {
+	defer func(orig func(time.Duration) time.Duration) { jitter = orig }(jitter)
+	jitter = func(time.Duration) time.Duration { return 0 }
+
+	tests := []struct {
+		name    string
+		err     error
+		expSql  string
+		expArgs func(msg *Message) []driver.Value
+	}{
+		{
+			name:   "retriable errors consume a retry attempt instead of a push attempt",
+			err:    NewRetriableError(errors.New("kafka: client has run out of available brokers")),
+			expSql: "UPDATE outbox SET error_reason = \\?, max_retry_attempts = 20 WHERE id = \\?",
+			expArgs: func(msg *Message) []driver.Value {
+				// the backoff grows with the 4th retry, rather than the push attempts
+				return []driver.Value{msg.ErrorReason.Error(), "retriable", (time.Second * 4).Microseconds(), msg.Id}
+			},
+		},
+		{
+			name:   "unclassified errors consume a push attempt",
+			err:    errors.New("oops"),
+			expSql: "UPDATE outbox SET error_reason = \\?, max_push_attempts = 10 WHERE id = \\?",
+			expArgs: func(msg *Message) []driver.Value {
+				return []driver.Value{msg.ErrorReason.Error(), "unclassified", (time.Second * 2).Microseconds(), msg.Id}
+			},
+		},
+		{
+			name:   "permanent errors mark the message as errored",
+			err:    NewPermanentError(errors.New("kafka server: Message was too large")),
+			expSql: "UPDATE outbox SET error_reason = \\?, errored = 1 WHERE id = \\?",
+			expArgs: func(msg *Message) []driver.Value {
+				return []driver.Value{msg.ErrorReason.Error(), "permanent", msg.Id}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New()
+			defer db.Close()
+
+			batch := createMockBatch(uuid.New())
+			msg := batch.Messages[1]
+			msg.ErrorReason = tt.err
+			msg.PushAttempts = 2
+			msg.RetryAttempts = 3
+
+			cfg := &config.Config{KafkaPublishAttempts: 10, KafkaRetryAttempts: 20, KafkaRetryBaseDelay: time.Second, KafkaRetryMaxDelay: time.Hour}
+			repo := NewRepositoryWithQueryProvider(db, cfg, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+
+			mock.ExpectBegin()
+			mock.ExpectExec(tt.expSql).
+				WithArgs(tt.expArgs(msg)...).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			mock.ExpectExec("UPDATE outbox SET push_completed_at =.* WHERE id IN.*").
+				WithArgs(batch.Messages[0].Id, batch.Messages[2].Id).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+
+			mock.ExpectCommit()
+
+			repo.CommitBatch(context.Background(), batch)
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("some SQL expectations were not met: %s", err)
+			}
+		})
+	}
+}
+
 func TestRepository_CommitBatchWithTransactionCreateError(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
@@ -237,7 +343,7 @@ func TestRepository_CommitBatchWithErroredMessageUpdateQueryError(t *testing.T)
 
 	mock.ExpectBegin()
 	mock.ExpectExec("UPDATE outbox SET error_reason =.* WHERE id =.*").
-		WithArgs(batch.Messages[1].ErrorReason.Error(), batch.Messages[1].Id).
+		WithArgs(batch.Messages[1].ErrorReason.Error(), "unclassified", int64(0), batch.Messages[1].Id).
 		WillReturnError(errors.New("oops"))
 
 	mock.ExpectExec("UPDATE outbox SET push_completed_at =.* WHERE id IN.*").
@@ -332,45 +438,215 @@ func TestRepository_DeletePublishedWithError(t *testing.T) {
 	}
 }
 
-func TestRepository_GetQueueSize(t *testing.T) {
+func TestRepository_GetQueueSizeByTopic(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
 	ctx := context.Background()
 
-	rows := sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(10)
+	rows := sqlmock.NewRows([]string{"topic", "COUNT(*)"}).AddRow("product", 10).AddRow("price", 2)
 	repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
-	mock.ExpectQuery("SELECT COUNT.*WHERE.*").
+	mock.ExpectQuery("SELECT topic, COUNT.*WHERE.*GROUP BY topic").
 		WillReturnRows(rows)
 
-	size, err := repo.GetQueueSize(ctx)
+	sizes, err := repo.GetQueueSizeByTopic(ctx)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
 
-	if size != 10 {
-		t.Errorf("expected the queue size to be 10, but got %d", size)
+	if diff := deep.Equal(map[string]uint{"product": 10, "price": 2}, sizes); diff != nil {
+		t.Error(diff)
 	}
 }
 
-func TestRepository_GetTotalSize(t *testing.T) {
+func TestRepository_GetTotalSizeByTopic(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
 	ctx := context.Background()
 
-	rows := sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(99)
+	rows := sqlmock.NewRows([]string{"topic", "COUNT(*)"}).AddRow("product", 99)
+	repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+	mock.ExpectQuery("SELECT topic, COUNT.*GROUP BY topic").
+		WillReturnRows(rows)
+
+	sizes, err := repo.GetTotalSizeByTopic(ctx)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if diff := deep.Equal(map[string]uint{"product": 99}, sizes); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRepository_GetQueueSizeByTopicWithError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+	mock.ExpectQuery("SELECT topic, COUNT.*").
+		WillReturnError(errors.New("oops"))
+
+	if _, err := repo.GetQueueSizeByTopic(context.Background()); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
+
+func TestRepository_GetOldestUnpublishedAge(t *testing.T) {
+	tests := []struct {
+		name   string
+		micros any
+		exp    time.Duration
+	}{
+		{name: "with unpublished messages", micros: int64(90 * time.Second / time.Microsecond), exp: time.Second * 90},
+		{name: "with no unpublished messages", micros: nil, exp: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New()
+			defer db.Close()
+
+			rows := sqlmock.NewRows([]string{"age"}).AddRow(tt.micros)
+			repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+			mock.ExpectQuery("SELECT TIMESTAMPDIFF.*WHERE push_completed_at IS NULL AND errored = 0").
+				WillReturnRows(rows)
+
+			age, err := repo.GetOldestUnpublishedAge(context.Background())
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			if age != tt.exp {
+				t.Errorf("expected the oldest unpublished age to be %s, but got %s", tt.exp, age)
+			}
+		})
+	}
+}
+
+func TestRepository_GetErroredCount(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(3)
 	repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
-	mock.ExpectQuery("SELECT COUNT.*").
+	mock.ExpectQuery("SELECT COUNT.*WHERE errored = 1").
 		WillReturnRows(rows)
 
-	size, err := repo.GetTotalSize(ctx)
+	count, err := repo.GetErroredCount(context.Background())
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
 
-	if size != 99 {
-		t.Errorf("expected the total size to be 10, but got %d", size)
+	if count != 3 {
+		t.Errorf("expected the errored count to be 3, but got %d", count)
+	}
+}
+
+func TestRepository_CountErrored(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	ctx := context.Background()
+
+	rows := sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(7)
+	repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+	mock.ExpectQuery("SELECT COUNT.* WHERE errored = 1 AND topic = ?").
+		WithArgs("product").
+		WillReturnRows(rows)
+
+	count, err := repo.CountErrored(ctx, s.ErroredFilter{Topic: "product"})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if count != 7 {
+		t.Errorf("expected the errored count to be 7, but got %d", count)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("some SQL expectations were not met: %s", err)
+	}
+}
+
+func TestRepository_RequeueErrored(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	ctx := context.Background()
+
+	repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+	mock.ExpectExec("UPDATE outbox SET errored = 0 WHERE errored = 1 AND topic = ?").
+		WithArgs("product").
+		WillReturnResult(sqlmock.NewResult(0, 7))
+
+	affRows, err := repo.RequeueErrored(ctx, s.ErroredFilter{Topic: "product"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if affRows != 7 {
+		t.Errorf("expected 7 affected rows, but got %d", affRows)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("some SQL expectations were not met: %s", err)
+	}
+}
+
+func TestRepository_RequeueErroredWithError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	ctx := context.Background()
+
+	repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+	mock.ExpectExec("UPDATE outbox SET errored = 0.*").
+		WillReturnError(errors.New("oops"))
+
+	if _, err := repo.RequeueErrored(ctx, s.ErroredFilter{}); err == nil {
+		t.Error("expected an error but got nil")
+	}
+}
+
+func TestRepository_ReleaseStaleBatches(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	ctx := context.Background()
+
+	repo := NewRepositoryWithQueryProvider(db, &config.Config{StaleBatchTimeout: time.Minute}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+	mock.ExpectExec("UPDATE outbox SET batch_id = NULL").
+		WithArgs(sqlmock.AnyArg(), 0).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	released, err := repo.ReleaseStaleBatches(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if released != 2 {
+		t.Errorf("expected 2 released messages, but got %d", released)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("some SQL expectations were not met: %s", err)
+	}
+}
+
+func TestRepository_ReleaseStaleBatchesWithError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	ctx := context.Background()
+
+	repo := NewRepositoryWithQueryProvider(db, &config.Config{}, config.Database{Driver: config.MySQL}, &mockQueryProvider{})
+	mock.ExpectExec("UPDATE outbox SET batch_id = NULL").
+		WillReturnError(errors.New("oops"))
+
+	if _, err := repo.ReleaseStaleBatches(ctx); err == nil {
+		t.Error("expected an error but got nil")
 	}
 }
 
@@ -460,6 +736,10 @@ func getExpectedMessageBatchForTest(batchId uuid.UUID, pushStarted time.Time, pu
 				Topic:          "event.product",
 				Key:            "key-0",
 				PartitionKey:   "partition-key-0",
+				CreatedAt: sql.NullTime{
+					Time:  pushStarted,
+					Valid: true,
+				},
 			},
 			{
 				Id:      124,
@@ -478,6 +758,16 @@ func getExpectedMessageBatchForTest(batchId uuid.UUID, pushStarted time.Time, pu
 				Topic:          "event.price",
 				Key:            "key-1",
 				PartitionKey:   "partition-key-1",
+				Partition: sql.NullInt32{
+					Int32: 3,
+					Valid: true,
+				},
+				Tombstone:     true,
+				RetryAttempts: 4,
+				CreatedAt: sql.NullTime{
+					Time:  pushStarted,
+					Valid: true,
+				},
 			},
 		},
 	}
@@ -494,22 +784,50 @@ func (m mockQueryProvider) BatchCreationSql(batchSize int) string {
 	return fmt.Sprintf("UPDATE outbox LIMIT %d", batchSize)
 }
 
+func (m mockQueryProvider) StaleBatchReleaseSql() string {
+	return "UPDATE outbox SET batch_id = NULL, push_started_at = NULL WHERE push_started_at < ? AND errored = ?"
+}
+
 func (m mockQueryProvider) BatchFetchSql() string {
 	return fmt.Sprintf("SELECT %s FROM outbox", columns)
 }
 
 func (m mockQueryProvider) MessageErroredUpdateSql(maxPushAttempts int) string {
-	return "UPDATE outbox SET error_reason = ? WHERE id = ?"
+	return fmt.Sprintf("UPDATE outbox SET error_reason = ?, max_push_attempts = %d WHERE id = ?", maxPushAttempts)
+}
+
+func (m mockQueryProvider) MessageRetryUpdateSql(maxRetryAttempts int) string {
+	return fmt.Sprintf("UPDATE outbox SET error_reason = ?, max_retry_attempts = %d WHERE id = ?", maxRetryAttempts)
+}
+
+func (m mockQueryProvider) MessagePermanentlyErroredUpdateSql() string {
+	return "UPDATE outbox SET error_reason = ?, errored = 1 WHERE id = ?"
+}
+
+func (m mockQueryProvider) MessageDeadLetteredUpdateSql() string {
+	return "UPDATE outbox SET error_reason = ?, dead_lettered_at = NOW() WHERE id = ?"
 }
 
 func (m mockQueryProvider) DeletePublishedMessagesSql() string {
 	return "DELETE FROM outbox WHERE push_completed_at <= ?"
 }
 
-func (m mockQueryProvider) GetQueueSizeSql() string {
-	return "SELECT COUNT(*) FROM outbox WHERE push_completed_at IS NULL"
+func (m mockQueryProvider) GetQueueSizeByTopicSql() string {
+	return "SELECT topic, COUNT(*) FROM outbox WHERE push_completed_at IS NULL GROUP BY topic"
+}
+
+func (m mockQueryProvider) GetTotalSizeByTopicSql() string {
+	return "SELECT topic, COUNT(*) FROM outbox GROUP BY topic"
+}
+
+func (m mockQueryProvider) OldestUnpublishedAgeSql() string {
+	return "SELECT TIMESTAMPDIFF(MICROSECOND, MIN(created_at), NOW()) FROM outbox WHERE push_completed_at IS NULL AND errored = 0"
+}
+
+func (m mockQueryProvider) CountErroredSql(f s.ErroredFilter) (string, []any) {
+	return "SELECT COUNT(*) FROM outbox WHERE errored = 1 AND topic = ?", []any{f.Topic}
 }
 
-func (m mockQueryProvider) GetTotalSizeSql() string {
-	return "SELECT COUNT(*) FROM outbox"
+func (m mockQueryProvider) RequeueErroredSql(f s.ErroredFilter) (string, []any) {
+	return "UPDATE outbox SET errored = 0 WHERE errored = 1 AND topic = ?", []any{f.Topic}
 }
@@ -8,18 +8,24 @@ import (
 	"time"
 
 	"inviqa/kafka-outbox-relay/outbox"
+	s "inviqa/kafka-outbox-relay/outbox/data/sql"
 )
 
 type MockRepository struct {
 	sync.RWMutex
 	getBatchCallCount   int
-	mockQueueSize       uint
-	mockTotalSize       uint
+	releaseCallCount    int
+	staleMessages       int64
+	mockQueueSize       map[string]uint
+	mockTotalSize       map[string]uint
+	oldestAge           time.Duration
 	batchesToReturn     []*outbox.Batch
 	committed           map[*outbox.Batch]bool
 	batchesCommitted    []*outbox.Batch
 	returnError         bool
 	deletedRowsCount    int64
+	erroredCount        int64
+	requeued            []s.ErroredFilter
 	returnNoEventsError bool
 }
 
@@ -27,6 +33,8 @@ func NewMockRepository() *MockRepository {
 	return &MockRepository{
 		batchesToReturn: []*outbox.Batch{},
 		committed:       map[*outbox.Batch]bool{},
+		mockQueueSize:   map[string]uint{},
+		mockTotalSize:   map[string]uint{},
 	}
 }
 
@@ -46,6 +54,21 @@ func (mr *MockRepository) GetBatch(ctx context.Context) (*outbox.Batch, error) {
 	return mr.popBatch(), nil
 }
 
+func (mr *MockRepository) ReleaseStaleBatches(ctx context.Context) (int64, error) {
+	mr.Lock()
+	defer mr.Unlock()
+	mr.releaseCallCount++
+
+	if mr.returnError {
+		return 0, errors.New("oops")
+	}
+
+	released := mr.staleMessages
+	mr.staleMessages = 0
+
+	return released, nil
+}
+
 func (mr *MockRepository) CommitBatch(ctx context.Context, batch *outbox.Batch) {
 	mr.Lock()
 	defer mr.Unlock()
@@ -91,26 +114,72 @@ func (mr *MockRepository) DeletePublished(ctx context.Context, olderThan time.Ti
 	return mr.deletedRowsCount, nil
 }
 
-func (mr *MockRepository) GetQueueSize() (uint, error) {
+func (mr *MockRepository) CountErrored(ctx context.Context, f s.ErroredFilter) (int64, error) {
 	if mr.returnError {
 		return 0, errors.New("oops")
 	}
+	return mr.erroredCount, nil
+}
+
+func (mr *MockRepository) RequeueErrored(ctx context.Context, f s.ErroredFilter) (int64, error) {
+	mr.Lock()
+	defer mr.Unlock()
+	if mr.returnError {
+		return 0, errors.New("oops")
+	}
+	mr.requeued = append(mr.requeued, f)
+	return mr.erroredCount, nil
+}
+
+func (mr *MockRepository) GetQueueSizeByTopic(ctx context.Context) (map[string]uint, error) {
+	if mr.returnError {
+		return nil, errors.New("oops")
+	}
 
 	return mr.mockQueueSize, nil
 }
 
-func (mr *MockRepository) GetTotalSize() (uint, error) {
+func (mr *MockRepository) GetTotalSizeByTopic(ctx context.Context) (map[string]uint, error) {
 	if mr.returnError {
-		return 0, errors.New("oops")
+		return nil, errors.New("oops")
 	}
 
 	return mr.mockTotalSize, nil
 }
 
+func (mr *MockRepository) GetOldestUnpublishedAge(ctx context.Context) (time.Duration, error) {
+	if mr.returnError {
+		return 0, errors.New("oops")
+	}
+
+	return mr.oldestAge, nil
+}
+
+func (mr *MockRepository) GetErroredCount(ctx context.Context) (uint, error) {
+	if mr.returnError {
+		return 0, errors.New("oops")
+	}
+
+	return uint(mr.erroredCount), nil
+}
+
 func (mr *MockRepository) GetBatchCallCount() int {
 	return mr.getBatchCallCount
 }
 
+func (mr *MockRepository) ReleaseCallCount() int {
+	mr.RLock()
+	defer mr.RUnlock()
+
+	return mr.releaseCallCount
+}
+
+func (mr *MockRepository) SetStaleMessages(count int64) {
+	mr.Lock()
+	defer mr.Unlock()
+	mr.staleMessages = count
+}
+
 func (mr *MockRepository) ReturnErrors() {
 	mr.returnError = true
 }
@@ -119,12 +188,12 @@ func (mr *MockRepository) ReturnNoEventsError() {
 	mr.returnNoEventsError = true
 }
 
-func (mr *MockRepository) SetQueueSize(size uint) {
-	mr.mockQueueSize = size
+func (mr *MockRepository) SetQueueSize(topic string, size uint) {
+	mr.mockQueueSize[topic] = size
 }
 
-func (mr *MockRepository) SetTotalSize(size uint) {
-	mr.mockTotalSize = size
+func (mr *MockRepository) SetTotalSize(topic string, size uint) {
+	mr.mockTotalSize[topic] = size
 }
 
 func (mr *MockRepository) popBatch() *outbox.Batch {
@@ -141,3 +210,17 @@ func (mr *MockRepository) popBatch() *outbox.Batch {
 func (mr *MockRepository) SetDeletedRowsCount(c int64) {
 	mr.deletedRowsCount = c
 }
+
+func (mr *MockRepository) SetErroredCount(c int64) {
+	mr.erroredCount = c
+}
+
+func (mr *MockRepository) SetOldestUnpublishedAge(age time.Duration) {
+	mr.oldestAge = age
+}
+
+func (mr *MockRepository) RequeuedFilters() []s.ErroredFilter {
+	mr.RLock()
+	defer mr.RUnlock()
+	return mr.requeued
+}
@@ -36,7 +36,7 @@ func StartHttpServer(ctx context.Context, cfg *config.Config, dbs data.DBs) {
 
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
-	mux.Handle("/healthz", h.NewHealthzHandler(cfg.GetDependencySystemAddresses(), pingers))
+	mux.Handle("/healthz", h.NewHealthzHandlerForClusters(cfg.KafkaClusterAddresses(), pingers))
 	srv = http.Server{
 		Handler: mux,
 		Addr:    ":80",
@@ -0,0 +1,84 @@
+package prometheus
+
+import (
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	messagesPublished *prom.CounterVec
+	messagesFailed    *prom.CounterVec
+	messagesErrored   *prom.CounterVec
+	publishLag        prom.Histogram
+	batchSize         prom.Histogram
+	batchDuration     prom.Histogram
+	getBatchDuration  *prom.HistogramVec
+)
+
+func init() {
+	messagesPublished = promauto.NewCounterVec(prom.CounterOpts{
+		Name: "kafka_outbox_messages_published_total",
+		Help: "The number of messages successfully published to Kafka",
+	}, []string{"database", "topic"})
+
+	messagesFailed = promauto.NewCounterVec(prom.CounterOpts{
+		Name: "kafka_outbox_messages_failed_total",
+		Help: "The number of attempts to publish a message to Kafka that failed, by error class (retriable, permanent or unclassified)",
+	}, []string{"database", "topic", "class"})
+
+	messagesErrored = promauto.NewCounterVec(prom.CounterOpts{
+		Name: "kafka_outbox_messages_errored_total",
+		Help: "The number of messages marked as errored, that will not be published again",
+	}, []string{"database", "topic"})
+
+	publishLag = promauto.NewHistogram(prom.HistogramOpts{
+		Name:    "kafka_outbox_publish_lag_seconds",
+		Help:    "The time from a message being created in the outbox to it being acknowledged by Kafka",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600},
+	})
+
+	batchSize = promauto.NewHistogram(prom.HistogramOpts{
+		Name:    "kafka_outbox_batch_size",
+		Help:    "The number of messages in each batch processed",
+		Buckets: prom.ExponentialBuckets(1, 2, 12),
+	})
+
+	batchDuration = promauto.NewHistogram(prom.HistogramOpts{
+		Name:    "kafka_outbox_batch_duration_seconds",
+		Help:    "The time taken to publish and commit each batch of messages",
+		Buckets: prom.DefBuckets,
+	})
+
+	getBatchDuration = promauto.NewHistogramVec(prom.HistogramOpts{
+		Name:    "kafka_outbox_get_batch_duration_seconds",
+		Help:    "The time taken to create and fetch a new batch of messages from the outbox",
+		Buckets: prom.DefBuckets,
+	}, []string{"database"})
+}
+
+func ObserveMessagePublished(database, topic string) {
+	messagesPublished.WithLabelValues(database, topic).Inc()
+}
+
+func ObserveMessageFailed(database, topic, class string) {
+	messagesFailed.WithLabelValues(database, topic, class).Inc()
+}
+
+func ObserveMessageErrored(database, topic string) {
+	messagesErrored.WithLabelValues(database, topic).Inc()
+}
+
+func ObservePublishLag(lag time.Duration) {
+	publishLag.Observe(lag.Seconds())
+}
+
+func ObserveBatch(size int, duration time.Duration) {
+	batchSize.Observe(float64(size))
+	batchDuration.Observe(duration.Seconds())
+}
+
+func ObserveGetBatchDuration(database string, duration time.Duration) {
+	getBatchDuration.WithLabelValues(database).Observe(duration.Seconds())
+}
@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestObserveMessageCounters(t *testing.T) {
+	published := messagesPublished.WithLabelValues("db", "product")
+	failed := messagesFailed.WithLabelValues("db", "product", "permanent")
+	errored := messagesErrored.WithLabelValues("db", "product")
+	beforePublished, beforeFailed, beforeErrored := testutil.ToFloat64(published), testutil.ToFloat64(failed), testutil.ToFloat64(errored)
+
+	ObserveMessagePublished("db", "product")
+	ObserveMessagePublished("db", "product")
+	ObserveMessageFailed("db", "product", "permanent")
+	ObserveMessageErrored("db", "product")
+
+	if actual := testutil.ToFloat64(published) - beforePublished; actual != 2.00 {
+		t.Errorf("expected published messages to have increased by 2.000000, but got %f", actual)
+	}
+
+	if actual := testutil.ToFloat64(failed) - beforeFailed; actual != 1.00 {
+		t.Errorf("expected failed messages to have increased by 1.000000, but got %f", actual)
+	}
+
+	if actual := testutil.ToFloat64(errored) - beforeErrored; actual != 1.00 {
+		t.Errorf("expected errored messages to have increased by 1.000000, but got %f", actual)
+	}
+}
+
+func TestObserveHistograms(t *testing.T) {
+	before := histogramSampleCount(t, getBatchDuration.WithLabelValues("db").(prom.Metric))
+	beforeLag := histogramSampleCount(t, publishLag)
+	beforeSize := histogramSampleCount(t, batchSize)
+
+	ObservePublishLag(time.Second)
+	ObserveBatch(10, time.Millisecond*250)
+	ObserveGetBatchDuration("db", time.Millisecond*20)
+
+	if actual := histogramSampleCount(t, getBatchDuration.WithLabelValues("db").(prom.Metric)) - before; actual != 1 {
+		t.Errorf("expected 1 get batch duration to have been observed, but got %d", actual)
+	}
+
+	if actual := histogramSampleCount(t, publishLag) - beforeLag; actual != 1 {
+		t.Errorf("expected 1 publish lag to have been observed, but got %d", actual)
+	}
+
+	if actual := histogramSampleCount(t, batchSize) - beforeSize; actual != 1 {
+		t.Errorf("expected 1 batch size to have been observed, but got %d", actual)
+	}
+}
+
+func histogramSampleCount(t *testing.T, m prom.Metric) uint64 {
+	pb := &dto.Metric{}
+	if err := m.Write(pb); err != nil {
+		t.Fatalf("unable to read histogram: %s", err)
+	}
+
+	return pb.GetHistogram().GetSampleCount()
+}
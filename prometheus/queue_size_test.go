@@ -1,44 +0,0 @@
-package prometheus
-
-import (
-	"context"
-	"testing"
-	"time"
-
-	"inviqa/kafka-outbox-relay/outbox/test"
-
-	"github.com/prometheus/client_golang/prometheus/testutil"
-)
-
-func TestObserveQueueSize(t *testing.T) {
-	repo1 := test.NewMockRepository()
-	repo1.SetQueueSize(32)
-	repo2 := test.NewMockRepository()
-	repo2.SetQueueSize(10)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	go ObserveQueueSize(ctx, []Sizer{repo1, repo2})
-	time.Sleep(time.Millisecond * 100)
-	cancel()
-
-	actual := testutil.ToFloat64(outboxQueueSize)
-	if actual != 42.00 {
-		t.Errorf("expected outboxQueueSize to be 32.000000, but got %f", actual)
-	}
-}
-
-func TestObserveQueueSize_WithRepositoryError(t *testing.T) {
-	outboxQueueSize.Set(0.0)
-	repo := test.NewMockRepository()
-	repo.ReturnErrors()
-
-	ctx, cancel := context.WithCancel(context.Background())
-	go ObserveQueueSize(ctx, []Sizer{repo})
-	time.Sleep(time.Millisecond * 100)
-	cancel()
-
-	actual := testutil.ToFloat64(outboxQueueSize)
-	if actual != 0.00 {
-		t.Errorf("expected outboxQueueSize to be 0.000000, but got %f", actual)
-	}
-}
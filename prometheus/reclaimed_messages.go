@@ -0,0 +1,21 @@
+package prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var reclaimedMessages *prom.CounterVec
+
+func init() {
+	reclaimedMessages = promauto.NewCounterVec(prom.CounterOpts{
+		Name: "kafka_outbox_reclaimed_messages_total",
+		Help: "The number of messages reclaimed from stale batches, abandoned by a relay that did not complete them",
+	}, []string{"database"})
+}
+
+func ObserveReclaimedMessages(database string, count int64) {
+	if count > 0 {
+		reclaimedMessages.WithLabelValues(database).Add(float64(count))
+	}
+}
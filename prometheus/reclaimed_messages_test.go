@@ -0,0 +1,21 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveReclaimedMessages(t *testing.T) {
+	before := testutil.ToFloat64(reclaimedMessages.WithLabelValues("orders"))
+
+	ObserveReclaimedMessages("orders", 3)
+	ObserveReclaimedMessages("orders", 0)
+	ObserveReclaimedMessages("orders", 2)
+	ObserveReclaimedMessages("products", 1)
+
+	actual := testutil.ToFloat64(reclaimedMessages.WithLabelValues("orders")) - before
+	if actual != 5.00 {
+		t.Errorf("expected reclaimedMessages to have increased by 5.000000, but got %f", actual)
+	}
+}
@@ -0,0 +1,162 @@
+package prometheus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"inviqa/kafka-outbox-relay/log"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// scrapeTimeout limits how long the size queries can take when they are run
+// for each scrape.
+const scrapeTimeout = time.Second * 10
+
+var (
+	queueSizeDesc = prom.NewDesc(
+		"kafka_outbox_queue_size",
+		"The current size of the outbox (all unpublished messages)",
+		[]string{"database", "topic"}, nil,
+	)
+	totalSizeDesc = prom.NewDesc(
+		"kafka_outbox_total_size",
+		"The total size of the outbox (all messages)",
+		[]string{"database", "topic"}, nil,
+	)
+	oldestUnpublishedAgeDesc = prom.NewDesc(
+		"kafka_outbox_oldest_unpublished_age_seconds",
+		"The age of the oldest message in the outbox that is waiting to be published, excluding errored messages",
+		[]string{"database"}, nil,
+	)
+	erroredMessagesDesc = prom.NewDesc(
+		"kafka_outbox_errored_messages",
+		"The current number of errored messages in the outbox, that will not be published again",
+		[]string{"database"}, nil,
+	)
+)
+
+// SizeCollector is a prometheus.Collector that reports the queue and total
+// size of the outbox in each database, for each topic, along with the age of
+// the oldest unpublished message and the number of errored messages in each
+// database.
+type SizeCollector struct {
+	sizers   map[string]Sizer
+	interval time.Duration
+
+	mu      sync.RWMutex
+	metrics []prom.Metric
+}
+
+// NewSizeCollector creates a SizeCollector for the sizers, keyed by database
+// name. If the interval is zero then the databases are queried on each scrape,
+// otherwise they are queried in the background by Run, and each scrape returns
+// the last results.
+func NewSizeCollector(sizers map[string]Sizer, interval time.Duration) *SizeCollector {
+	return &SizeCollector{
+		sizers:   sizers,
+		interval: interval,
+	}
+}
+
+// ObserveSizes registers a SizeCollector for the sizers with the default
+// registry, and runs it until the context is cancelled.
+func ObserveSizes(ctx context.Context, sizers map[string]Sizer, interval time.Duration) {
+	c := NewSizeCollector(sizers, interval)
+	prom.MustRegister(c)
+	c.Run(ctx)
+}
+
+func (c *SizeCollector) Describe(ch chan<- *prom.Desc) {
+	ch <- queueSizeDesc
+	ch <- totalSizeDesc
+	ch <- oldestUnpublishedAgeDesc
+	ch <- erroredMessagesDesc
+}
+
+func (c *SizeCollector) Collect(ch chan<- prom.Metric) {
+	metrics := c.cached()
+	if c.interval == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+		defer cancel()
+		metrics = c.collect(ctx)
+	}
+
+	for _, m := range metrics {
+		ch <- m
+	}
+}
+
+// Run queries the databases on each interval until the context is cancelled.
+// It returns straight away if the databases are queried on each scrape.
+func (c *SizeCollector) Run(ctx context.Context) {
+	if c.interval == 0 {
+		return
+	}
+
+	for {
+		metrics := c.collect(ctx)
+		c.mu.Lock()
+		c.metrics = metrics
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.interval):
+		}
+	}
+}
+
+func (c *SizeCollector) cached() []prom.Metric {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.metrics
+}
+
+func (c *SizeCollector) collect(ctx context.Context) []prom.Metric {
+	var metrics []prom.Metric
+	for db, sizer := range c.sizers {
+		logger := log.Logger.WithField("database", db)
+
+		age, err := sizer.GetOldestUnpublishedAge(ctx)
+		if err != nil {
+			logger.WithError(err).Error("an error occurred determining the age of the oldest unpublished message")
+		} else {
+			metrics = append(metrics, prom.MustNewConstMetric(oldestUnpublishedAgeDesc, prom.GaugeValue, age.Seconds(), db))
+		}
+
+		errored, err := sizer.GetErroredCount(ctx)
+		if err != nil {
+			logger.WithError(err).Error("an error occurred determining the number of errored messages")
+		} else {
+			metrics = append(metrics, prom.MustNewConstMetric(erroredMessagesDesc, prom.GaugeValue, float64(errored), db))
+		}
+
+		totals, err := sizer.GetTotalSizeByTopic(ctx)
+		if err != nil {
+			logger.WithError(err).Error("an error occurred determining the total size of the outbox")
+			continue
+		}
+
+		queued, err := sizer.GetQueueSizeByTopic(ctx)
+		if err != nil {
+			logger.WithError(err).Error("an error occurred determining the size of the queue")
+			continue
+		}
+
+		for topic, total := range totals {
+			// topics with nothing left to publish are not returned by the queue
+			// size query, but should still be reported as empty
+			metrics = append(
+				metrics,
+				prom.MustNewConstMetric(totalSizeDesc, prom.GaugeValue, float64(total), db, topic),
+				prom.MustNewConstMetric(queueSizeDesc, prom.GaugeValue, float64(queued[topic]), db, topic),
+			)
+		}
+	}
+
+	return metrics
+}
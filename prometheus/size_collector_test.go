@@ -0,0 +1,81 @@
+package prometheus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"inviqa/kafka-outbox-relay/outbox/test"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newMockSizers() map[string]Sizer {
+	orders := test.NewMockRepository()
+	orders.SetQueueSize("order.created", 32)
+	orders.SetTotalSize("order.created", 100)
+	orders.SetTotalSize("order.cancelled", 5)
+	orders.SetOldestUnpublishedAge(time.Second * 90)
+	orders.SetErroredCount(4)
+
+	products := test.NewMockRepository()
+	products.SetQueueSize("product.updated", 10)
+	products.SetTotalSize("product.updated", 10)
+	products.SetOldestUnpublishedAge(time.Second)
+
+	customers := test.NewMockRepository()
+	customers.ReturnErrors()
+
+	return map[string]Sizer{
+		"orders":    orders,
+		"products":  products,
+		"customers": customers,
+	}
+}
+
+const expectedSizeMetrics = `
+# HELP kafka_outbox_errored_messages The current number of errored messages in the outbox, that will not be published again
+# TYPE kafka_outbox_errored_messages gauge
+kafka_outbox_errored_messages{database="orders"} 4
+kafka_outbox_errored_messages{database="products"} 0
+# HELP kafka_outbox_oldest_unpublished_age_seconds The age of the oldest message in the outbox that is waiting to be published, excluding errored messages
+# TYPE kafka_outbox_oldest_unpublished_age_seconds gauge
+kafka_outbox_oldest_unpublished_age_seconds{database="orders"} 90
+kafka_outbox_oldest_unpublished_age_seconds{database="products"} 1
+# HELP kafka_outbox_queue_size The current size of the outbox (all unpublished messages)
+# TYPE kafka_outbox_queue_size gauge
+kafka_outbox_queue_size{database="orders",topic="order.cancelled"} 0
+kafka_outbox_queue_size{database="orders",topic="order.created"} 32
+kafka_outbox_queue_size{database="products",topic="product.updated"} 10
+# HELP kafka_outbox_total_size The total size of the outbox (all messages)
+# TYPE kafka_outbox_total_size gauge
+kafka_outbox_total_size{database="orders",topic="order.cancelled"} 5
+kafka_outbox_total_size{database="orders",topic="order.created"} 100
+kafka_outbox_total_size{database="products",topic="product.updated"} 10
+`
+
+func TestSizeCollector_CollectOnScrape(t *testing.T) {
+	c := NewSizeCollector(newMockSizers(), 0)
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expectedSizeMetrics)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSizeCollector_CollectOnInterval(t *testing.T) {
+	c := NewSizeCollector(newMockSizers(), time.Minute)
+
+	if count := testutil.CollectAndCount(c); count != 0 {
+		t.Errorf("expected no metrics before the collector has run, but got %d", count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go c.Run(ctx)
+	time.Sleep(time.Millisecond * 100)
+	cancel()
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expectedSizeMetrics)); err != nil {
+		t.Error(err)
+	}
+}
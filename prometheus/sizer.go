@@ -1,6 +1,16 @@
 package prometheus
 
+import (
+	"context"
+	"time"
+)
+
+// Sizer is implemented by outbox repositories that can report the number of
+// messages in the outbox for each topic, and how far behind the relay is in
+// publishing them.
 type Sizer interface {
-	GetQueueSize() (uint, error)
-	GetTotalSize() (uint, error)
+	GetQueueSizeByTopic(ctx context.Context) (map[string]uint, error)
+	GetTotalSizeByTopic(ctx context.Context) (map[string]uint, error)
+	GetOldestUnpublishedAge(ctx context.Context) (time.Duration, error)
+	GetErroredCount(ctx context.Context) (uint, error)
 }
@@ -1,9 +0,0 @@
-package prometheus
-
-import (
-	"time"
-)
-
-var (
-	backoffTime = time.Second * 30
-)